@@ -0,0 +1,70 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 个人访问令牌页面，GET 展示列表，POST 创建或撤销令牌
+func accountTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user.Subject == "" {
+		http.Error(w, "请先登录", http.StatusForbidden)
+		return
+	}
+
+	var newToken string
+	var message string
+	if r.Method == http.MethodPost {
+		switch r.FormValue("action") {
+		case "create":
+			scope := r.FormValue("scope")
+			if scope != tokenScopeAdmin {
+				scope = tokenScopeRead
+			}
+			if scope == tokenScopeAdmin && user.Role != roleAdmin {
+				http.Error(w, "只有管理员可以创建管理令牌", http.StatusForbidden)
+				return
+			}
+			days, _ := strconv.Atoi(r.FormValue("days"))
+			if days < 0 {
+				days = 0
+			}
+			name := strings.TrimSpace(r.FormValue("name"))
+			if name == "" {
+				name = "未命名"
+			}
+			newToken, _ = createAPIToken(user.Subject, name, scope, time.Duration(days)*24*time.Hour)
+			message = "令牌已创建，请立即复制保存，关闭页面后将无法再次查看"
+		case "revoke":
+			if !revokeAPIToken(r.FormValue("id"), user.Subject) {
+				http.Error(w, "令牌不存在", http.StatusNotFound)
+				return
+			}
+			http.Redirect(w, r, "/account/tokens", http.StatusFound)
+			return
+		default:
+			http.Error(w, "未知操作", http.StatusBadRequest)
+			return
+		}
+	}
+
+	data := struct {
+		Config   Config
+		UserInfo UserInfo
+		Tokens   []APIToken
+		NewToken string
+		Message  string
+	}{
+		Config:   config,
+		UserInfo: user,
+		Tokens:   listAPITokens(user.Subject),
+		NewToken: newToken,
+		Message:  message,
+	}
+	tmpl := template.Must(template.New("tokens").Funcs(csrfFuncs(w, r)).Parse(tokensTemplate))
+	tmpl.Execute(w, data)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// 查找分类对应的访问规则，忽略大小写以免在大小写不敏感的文件系统上被绕过
+func findCategoryACL(category string) (CategoryACL, bool) {
+	for _, rule := range config.CategoryACL {
+		if strings.EqualFold(rule.Category, category) {
+			return rule, true
+		}
+	}
+	return CategoryACL{}, false
+}
+
+// 判断用户能否访问分类，未配置规则的分类对所有通过认证的用户可见。
+// 子分类同样可以配置规则，从最深的一级开始检查，路径上每一级的规则都需满足
+func categoryAllowed(user UserInfo, category string) bool {
+	if user.Provider == "share" {
+		return category == user.ShareCategory || strings.HasPrefix(category, user.ShareCategory+"/")
+	}
+	for p := category; p != "." && p != ""; p = path.Dir(p) {
+		if rule, ok := findCategoryACL(p); ok && !ruleAllows(rule, user) {
+			return false
+		}
+	}
+	return true
+}
+
+// 用户是否满足单条访问规则中的任一名单
+func ruleAllows(rule CategoryACL, user UserInfo) bool {
+	if user.Role != "" && slices.Contains(rule.Roles, user.Role) {
+		return true
+	}
+	switch user.Provider {
+	case "password":
+		return false
+	case "user", "proxy":
+		return slices.Contains(rule.Users, user.Username)
+	case "linuxdo":
+		if slices.Contains(rule.LinuxdoUsers, user.Username) {
+			return true
+		}
+	}
+	return user.Username != "" && slices.Contains(rule.OAuthUsers, user.Provider+":"+user.Username)
+}
+
+// 当前用户可见的分类列表
+func visibleCategories(user UserInfo) []Category {
+	return allowedCategories(user, getCategories())
+}
+
+// 过滤出用户有权访问的分类，也用于分类页中的子分类
+func allowedCategories(user UserInfo, categories []Category) []Category {
+	if len(config.CategoryACL) == 0 {
+		return categories
+	}
+	list := []Category{}
+	for _, category := range categories {
+		if categoryAllowed(user, category.Path) {
+			list = append(list, category)
+		}
+	}
+	return list
+}
+
+// 解析请求路径中的分类，返回相对图片目录、以 / 分隔的路径，即图片存储中的名称
+func resolveCategoryPath(encoded string) (string, bool) {
+	category, err := url.PathUnescape(encoded)
+	if err != nil {
+		return "", false
+	}
+	rel := path.Clean(strings.TrimLeft(category, "/"))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || !fs.ValidPath(rel) {
+		return "", false
+	}
+	return rel, true
+}
+
+// 图片访问控制，无权访问的分类按不存在处理，避免泄露隐藏相册
+func imageACLMiddleware(next http.Handler) http.Handler {
+	if len(config.CategoryACL) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		// 禁止列出图片根目录，其中包含隐藏分类的名称
+		if p == "" || !categoryAllowed(currentUser(r), p) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
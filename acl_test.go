@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 以指定用户身份发起请求
+func requestAs(method, target string, user UserInfo) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	return r.WithContext(context.WithValue(r.Context(), userCtxKey, user))
+}
+
+func TestCategoryAllowed(t *testing.T) {
+	config.CategoryACL = []CategoryACL{
+		{Category: "Private", Users: []string{"alice"}, Roles: []string{roleAdmin}, LinuxdoUsers: []string{"ld"}},
+	}
+	t.Cleanup(func() { config.CategoryACL = nil })
+	for _, tt := range []struct {
+		name     string
+		user     UserInfo
+		category string
+		allowed  bool
+	}{
+		{"名单中的用户", UserInfo{Username: "alice", Role: roleViewer, Provider: "user"}, "Private", true},
+		{"其他用户", UserInfo{Username: "bob", Role: roleViewer, Provider: "user"}, "Private", false},
+		{"大小写不同", UserInfo{Username: "bob", Role: roleViewer, Provider: "user"}, "private", false},
+		{"分类中的图片", UserInfo{Username: "bob", Role: roleViewer, Provider: "user"}, "Private/a.jpg", false},
+		{"角色", UserInfo{Username: "carol", Role: roleAdmin, Provider: "user"}, "Private", true},
+		{"Linux do 用户", UserInfo{Username: "ld", Provider: "linuxdo"}, "Private", true},
+		{"Linux do 同名本地用户", UserInfo{Username: "ld", Role: roleViewer, Provider: "user"}, "Private", false},
+		{"未配置规则的分类", UserInfo{Username: "bob", Role: roleViewer, Provider: "user"}, "Public", true},
+	} {
+		if got := categoryAllowed(tt.user, tt.category); got != tt.allowed {
+			t.Errorf("%s: %v，期望 %v", tt.name, got, tt.allowed)
+		}
+	}
+
+	setCategories([]Category{{Name: "Private", Path: "Private"}, {Name: "Public", Path: "Public"}})
+	t.Cleanup(func() { setCategories(nil) })
+	if list := visibleCategories(UserInfo{Username: "bob", Role: roleViewer, Provider: "user"}); len(list) != 1 || list[0].Path != "Public" {
+		t.Errorf("可见分类为 %v，期望只有 Public", list)
+	}
+}
+
+// 分类路径不能跳出图片目录
+func TestResolveCategoryPath(t *testing.T) {
+	for _, encoded := range []string{"..", "../conf", "a/../../conf", "%2e%2e%2fconf", ""} {
+		if rel, ok := resolveCategoryPath(encoded); ok {
+			t.Errorf("%q 解析为 %q，应被拒绝", encoded, rel)
+		}
+	}
+	if rel, ok := resolveCategoryPath("Trips%2F2024/./Japan"); !ok || rel != "Trips/2024/Japan" {
+		t.Errorf("解析结果为 %q %v", rel, ok)
+	}
+}
+
+// 子分类的访问规则同样生效，且不能放宽上级分类的规则
+func TestCategoryACLNested(t *testing.T) {
+	config.CategoryACL = []CategoryACL{
+		{Category: "AclTrips/Private", Users: []string{"alice"}},
+		{Category: "AclSecret", Users: []string{"alice"}},
+		{Category: "AclSecret/Open", Roles: []string{roleViewer}},
+	}
+	t.Cleanup(func() { config.CategoryACL = nil })
+	alice := UserInfo{Username: "alice", Role: roleViewer, Provider: "user"}
+	bob := UserInfo{Username: "bob", Role: roleViewer, Provider: "user"}
+
+	for _, tt := range []struct {
+		user     UserInfo
+		category string
+		allowed  bool
+	}{
+		{bob, "AclTrips", true},
+		{bob, "AclTrips/Public", true},
+		{bob, "AclTrips/Private", false},
+		{bob, "AclTrips/private/2024", false},
+		{bob, "AclTrips/Private/a.jpg", false},
+		{alice, "AclTrips/Private/2024", true},
+		{bob, "AclSecret/Open", false},
+		{alice, "AclSecret/Open", true},
+	} {
+		if got := categoryAllowed(tt.user, tt.category); got != tt.allowed {
+			t.Errorf("%s 访问 %s: %v，期望 %v", tt.user.Username, tt.category, got, tt.allowed)
+		}
+	}
+
+	writeImageFile(t, "AclTrips/a.jpg", []byte("jpg"))
+	writeImageFile(t, "AclTrips/Public/b.jpg", []byte("jpg"))
+	writeImageFile(t, "AclTrips/Private/c.jpg", []byte("jpg"))
+
+	rec := httptest.NewRecorder()
+	categoryJson(rec, requestAs(http.MethodGet, "/api/category/AclTrips", bob))
+	if !strings.Contains(rec.Body.String(), "AclTrips/Public") || strings.Contains(rec.Body.String(), "AclTrips/Private") {
+		t.Errorf("子分类列表未按规则过滤: %s", rec.Body)
+	}
+
+	images := imageACLMiddleware(archiveMiddleware(imageFileServer()))
+	rec = httptest.NewRecorder()
+	images.ServeHTTP(rec, requestAs(http.MethodGet, "/AclTrips/Private/c.jpg", bob))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("无权访问的子分类图片返回 %d，期望 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	downloadHandler(rec, requestAs(http.MethodGet, "/download/AclTrips.zip", bob))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "Private/c.jpg") {
+		t.Errorf("打包下载包含无权访问的子分类: %d", rec.Code)
+	}
+}
+
+// /images/ 路由只提供文件，不列出目录，也不提供相册配置与以点开头的文件
+func TestImagesNoListing(t *testing.T) {
+	writeImageFile(t, "Listing/a.jpg", []byte("jpg"))
+	writeImageFile(t, "Listing/album.yaml", []byte("title: 列表\n"))
+	writeImageFile(t, "Listing/.secret/b.jpg", []byte("jpg"))
+	handler := http.StripPrefix("/images/", imageACLMiddleware(archiveMiddleware(imageFileServer())))
+
+	for _, tt := range []struct {
+		target string
+		status int
+	}{
+		{"/images/Listing/a.jpg", http.StatusOK},
+		{"/images/", http.StatusNotFound},
+		{"/images/Listing/", http.StatusNotFound},
+		{"/images/Listing", http.StatusNotFound},
+		{"/images/Listing/album.yaml", http.StatusNotFound},
+		{"/images/Listing/.secret/", http.StatusNotFound},
+		{"/images/Listing/.secret/b.jpg", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: 返回 %d，期望 %d", tt.target, rec.Code, tt.status)
+		}
+	}
+}
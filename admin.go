@@ -0,0 +1,179 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 管理员权限中间件，需放在 AuthMiddleware 之后
+func adminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentUser(r).Role != roleAdmin {
+			http.Error(w, "需要管理员权限", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 会话主体的显示名称
+func subjectLabel(subject string) string {
+	kind, name, _ := strings.Cut(subject, ":")
+	switch kind {
+	case "password":
+		return "访问密码"
+	case "oauth":
+		providerName, username, _ := strings.Cut(name, ":")
+		if p, ok := findProvider(providerName); ok {
+			providerName = p.DisplayName
+		}
+		return username + "（" + providerName + "）"
+	}
+	return name
+}
+
+// 管理页面
+func adminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/admin/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	type sessionRow struct {
+		SessionRecord
+		User    string
+		Current bool
+	}
+	var current string
+	if cookie, err := r.Cookie("auth"); err == nil {
+		if claims, err := parseAuthToken(cookie.Value); err == nil {
+			current = claims.SessionID
+		}
+	}
+	var rows []sessionRow
+	for _, record := range listSessionRecords() {
+		rows = append(rows, sessionRow{
+			SessionRecord: record,
+			User:          subjectLabel(record.Subject),
+			Current:       record.ID == current,
+		})
+	}
+
+	type shareRow struct {
+		ShareLink
+		URL string
+	}
+	var shares []shareRow
+	for _, link := range listShareLinks() {
+		shares = append(shares, shareRow{
+			ShareLink: link,
+			URL:       config.WebAdderss + "/s/" + shareToken(link.ID),
+		})
+	}
+
+	type tokenRow struct {
+		APIToken
+		OwnerLabel string
+	}
+	var tokens []tokenRow
+	for _, token := range listAPITokens("") {
+		tokens = append(tokens, tokenRow{
+			APIToken:   token,
+			OwnerLabel: subjectLabel(token.Owner),
+		})
+	}
+
+	data := struct {
+		Config     Config
+		UserInfo   UserInfo
+		Sessions   []sessionRow
+		Shares     []shareRow
+		Tokens     []tokenRow
+		Categories []Category
+	}{
+		Config:     config,
+		UserInfo:   currentUser(r),
+		Sessions:   rows,
+		Shares:     shares,
+		Tokens:     tokens,
+		Categories: getCategories(),
+	}
+	tmpl := template.Must(template.New("admin").Funcs(csrfFuncs(w, r)).Parse(adminTemplate))
+	tmpl.Execute(w, data)
+}
+
+// 撤销会话，提交 id 撤销单个会话，提交 subject 撤销该用户的全部会话
+func adminRevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if id := r.FormValue("id"); id != "" {
+		revokeSession(id)
+	} else if subject := r.FormValue("subject"); subject != "" {
+		revokeSubjectSessions(subject)
+	}
+	http.Redirect(w, r, "/admin/", http.StatusFound)
+}
+
+// 生成分类分享链接
+func adminCreateShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	category, ok := resolveCategoryPath(url.PathEscape(r.FormValue("category")))
+	if !ok {
+		http.Error(w, "无效的分类", http.StatusBadRequest)
+		return
+	}
+	// 只能分享当前展示的分类，隐藏的分类与拼写错误的名称都不在缓存中
+	if !slices.ContainsFunc(getCategories(), func(c Category) bool { return c.Path == category }) {
+		http.Error(w, "分类不存在", http.StatusBadRequest)
+		return
+	}
+	hours, err := strconv.Atoi(r.FormValue("hours"))
+	if err != nil || hours < 1 {
+		http.Error(w, "有效期至少为1小时", http.StatusBadRequest)
+		return
+	}
+	maxViews, _ := strconv.Atoi(r.FormValue("max_views"))
+	if maxViews < 0 {
+		maxViews = 0
+	}
+	user := currentUser(r)
+	createdBy := user.Username
+	if createdBy == "" {
+		createdBy = "管理员"
+	}
+	if _, err := createShareLink(category, createdBy, time.Duration(hours)*time.Hour, maxViews, r.FormValue("password")); err != nil {
+		http.Error(w, "无法创建分享链接", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/", http.StatusFound)
+}
+
+// 撤销分享链接
+func adminRevokeShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revokeShareLink(r.FormValue("id"))
+	http.Redirect(w, r, "/admin/", http.StatusFound)
+}
+
+// 撤销任意用户的访问令牌
+func adminRevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revokeAPIToken(r.FormValue("id"), "")
+	http.Redirect(w, r, "/admin/", http.StatusFound)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const albumFileName = "album.yaml"
+
+// 分类目录中可选的 album.yaml，用于覆盖根据目录名推导出的展示信息
+type AlbumMeta struct {
+	Title         string   `yaml:"title"`
+	Description   string   `yaml:"description"`
+	Cover         string   `yaml:"cover"`          // 封面图片，相对分类目录的路径
+	Sort          string   `yaml:"sort"`           // 同级分类的排序键，未设置时按目录名排序
+	Tags          []string `yaml:"tags"`           // 标签
+	Hidden        bool     `yaml:"hidden"`         // 不在列表中展示，但仍可通过链接访问
+	ImageSort     string   `yaml:"image_sort"`     // 图片默认排序方式，覆盖站点配置
+	ImageOrder    string   `yaml:"image_order"`    // 图片默认排序方向：asc 或 desc
+	CategorySort  string   `yaml:"category_sort"`  // 子分类默认排序方式，覆盖站点配置
+	CategoryOrder string   `yaml:"category_order"` // 子分类默认排序方向：asc 或 desc
+}
+
+// 读取分类目录中的 album.yaml，文件不存在、无法解析或分类为归档时返回空配置
+func loadAlbumMeta(dirPath string) AlbumMeta {
+	var meta AlbumMeta
+	if isArchiveFile(dirPath) {
+		return meta
+	}
+	file := path.Join(dirPath, albumFileName)
+	content, err := fs.ReadFile(imageStore, file)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("无法读取相册配置 %s: %v", file, err)
+		}
+		return meta
+	}
+	if err := yaml.Unmarshal(content, &meta); err != nil {
+		log.Printf("无法解析相册配置 %s: %v", file, err)
+		return AlbumMeta{}
+	}
+	return meta
+}
+
+// 展示标题，未设置时使用目录名，归档分类去掉扩展名
+func (meta AlbumMeta) title(name string) string {
+	if meta.Title != "" {
+		return meta.Title
+	}
+	return archiveTitle(path.Base(name))
+}
+
+// 校验 album.yaml 中指定的封面，必须是分类目录内存在的媒体文件
+func (meta AlbumMeta) coverImage(dirPath string) (string, bool) {
+	if meta.Cover == "" {
+		return "", false
+	}
+	cover := path.Clean(strings.TrimPrefix(strings.ReplaceAll(meta.Cover, "\\", "/"), "/"))
+	if cover == "." || cover == ".." || strings.HasPrefix(cover, "../") {
+		return "", false
+	}
+	if _, ok := lookupMedia(cover); !ok {
+		return "", false
+	}
+	info, err := imageStore.Stat(path.Join(dirPath, cover))
+	if err != nil || info.IsDir() {
+		log.Printf("相册 %s 指定的封面 %s 不存在", dirPath, meta.Cover)
+		return "", false
+	}
+	return cover, true
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const tokensFileName = "conf/tokens.json"
+
+const (
+	tokenScopeRead  = "read"
+	tokenScopeAdmin = "admin"
+)
+
+// 接口访问令牌，只保存令牌的哈希
+type APIToken struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Owner      string    `json:"owner"` // 创建者的令牌主体，如 user:alice
+	Scope      string    `json:"scope"`
+	Hash       string    `json:"hash"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"` // 零值表示永不过期
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+var (
+	apiTokens   = make(map[string]*APIToken)
+	apiTokensMu sync.Mutex
+)
+
+func loadAPITokens() {
+	content, err := os.ReadFile(tokensFileName)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("无法读取令牌文件 %s: %v", tokensFileName, err)
+		return
+	}
+	var tokens []*APIToken
+	if err := json.Unmarshal(content, &tokens); err != nil {
+		log.Printf("无法解析令牌文件 %s: %v", tokensFileName, err)
+		return
+	}
+	for _, token := range tokens {
+		apiTokens[token.ID] = token
+	}
+}
+
+// 写回令牌文件，调用方需持有锁
+func saveAPITokens() {
+	tokens := make([]*APIToken, 0, len(apiTokens))
+	for _, token := range apiTokens {
+		tokens = append(tokens, token)
+	}
+	content, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		log.Printf("无法序列化访问令牌: %v", err)
+		return
+	}
+	if err := os.WriteFile(tokensFileName, content, 0600); err != nil {
+		log.Printf("无法写入令牌文件 %s: %v", tokensFileName, err)
+	}
+}
+
+func (t *APIToken) expired() bool {
+	return !t.ExpiresAt.IsZero() && !t.ExpiresAt.After(time.Now())
+}
+
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// 创建令牌，返回仅展示一次的明文令牌，格式为 plist_id.secret
+func createAPIToken(owner, name, scope string, ttl time.Duration) (string, APIToken) {
+	secret := hex.EncodeToString(generateRandomKey(24))
+	token := APIToken{
+		ID:        hex.EncodeToString(generateRandomKey(8)),
+		Name:      name,
+		Owner:     owner,
+		Scope:     scope,
+		Hash:      hashTokenSecret(secret),
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		token.ExpiresAt = token.CreatedAt.Add(ttl)
+	}
+
+	apiTokensMu.Lock()
+	defer apiTokensMu.Unlock()
+	apiTokens[token.ID] = &token
+	saveAPITokens()
+	return "plist_" + token.ID + "." + secret, token
+}
+
+// 撤销令牌，owner 非空时只能撤销自己的令牌
+func revokeAPIToken(id, owner string) bool {
+	apiTokensMu.Lock()
+	defer apiTokensMu.Unlock()
+	token, ok := apiTokens[id]
+	if !ok || (owner != "" && token.Owner != owner) {
+		return false
+	}
+	delete(apiTokens, id)
+	saveAPITokens()
+	return true
+}
+
+// 令牌列表，owner 为空时返回全部
+func listAPITokens(owner string) []APIToken {
+	apiTokensMu.Lock()
+	defer apiTokensMu.Unlock()
+	var tokens []APIToken
+	for _, token := range apiTokens {
+		if owner == "" || token.Owner == owner {
+			tokens = append(tokens, *token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens
+}
+
+// 校验明文令牌并记录使用时间
+func verifyAPIToken(raw string) (APIToken, bool) {
+	rest, ok := strings.CutPrefix(raw, "plist_")
+	if !ok {
+		return APIToken{}, false
+	}
+	id, secret, ok := strings.Cut(rest, ".")
+	if !ok {
+		return APIToken{}, false
+	}
+
+	apiTokensMu.Lock()
+	defer apiTokensMu.Unlock()
+	token, ok := apiTokens[id]
+	if !ok || token.expired() ||
+		subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hashTokenSecret(secret))) != 1 {
+		return APIToken{}, false
+	}
+	// 最多每分钟写盘一次，避免频繁调用时反复写文件
+	now := time.Now()
+	if now.Sub(token.LastUsedAt) > time.Minute {
+		token.LastUsedAt = now
+		saveAPITokens()
+	}
+	return *token, true
+}
+
+// 取出 Authorization: Bearer plist_… 头中的访问令牌。
+// 其他认证头（如 nginx auth_basic、oauth2-proxy 转发的令牌）不属于本服务，交由代理或cookie认证处理
+func apiTokenHeader(r *http.Request) (string, bool) {
+	scheme, raw, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	raw = strings.TrimSpace(raw)
+	return raw, strings.HasPrefix(raw, "plist_")
+}
+
+// 通过 Authorization: Bearer 头认证，只读令牌仅允许 GET 请求
+func bearerUser(r *http.Request) (UserInfo, bool) {
+	raw, ok := apiTokenHeader(r)
+	if !ok {
+		return UserInfo{}, false
+	}
+	token, ok := verifyAPIToken(raw)
+	if !ok {
+		return UserInfo{}, false
+	}
+	user, ok := resolveUser(r, token.Owner)
+	if !ok {
+		return UserInfo{}, false
+	}
+	if token.Scope != tokenScopeAdmin {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			return UserInfo{}, false
+		}
+		if user.Role == roleAdmin {
+			user.Role = roleViewer
+		}
+	}
+	return user, true
+}
+
+func hasAPIToken(r *http.Request) bool {
+	_, ok := apiTokenHeader(r)
+	return ok
+}
+
+// 是否为接口客户端，认证失败时返回 JSON 而不是跳转登录页
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") ||
+		hasAPIToken(r) ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": message,
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIToken(t *testing.T) {
+	raw, token := createAPIToken("user:alice", "ci", tokenScopeRead, 0)
+	if _, ok := verifyAPIToken(raw); !ok {
+		t.Fatal("新建的令牌无效")
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(raw, "plist_"), ".")
+	for _, bad := range []string{"plist_" + id + ".wrong", strings.TrimPrefix(raw, "plist_"), "plist_" + id} {
+		if _, ok := verifyAPIToken(bad); ok {
+			t.Errorf("%q 应无效", bad)
+		}
+	}
+
+	if revokeAPIToken(token.ID, "user:bob") {
+		t.Error("不能撤销他人的令牌")
+	}
+	if !revokeAPIToken(token.ID, "user:alice") {
+		t.Fatal("撤销令牌失败")
+	}
+	if _, ok := verifyAPIToken(raw); ok {
+		t.Error("撤销后令牌仍然有效")
+	}
+
+	raw, token = createAPIToken("user:alice", "ci", tokenScopeRead, time.Hour)
+	apiTokensMu.Lock()
+	apiTokens[token.ID].ExpiresAt = time.Now().Add(-time.Second)
+	apiTokensMu.Unlock()
+	if _, ok := verifyAPIToken(raw); ok {
+		t.Error("过期的令牌仍然有效")
+	}
+}
+
+// 只读令牌只能发起 GET 请求，且不具有管理员权限
+func TestBearerUserScope(t *testing.T) {
+	read, _ := createAPIToken("password:", "ci", tokenScopeRead, 0)
+	admin, _ := createAPIToken("password:", "ci", tokenScopeAdmin, 0)
+	for _, tt := range []struct {
+		name, raw, method, role string
+		ok                      bool
+	}{
+		{"只读令牌 GET", read, http.MethodGet, roleViewer, true},
+		{"只读令牌 POST", read, http.MethodPost, "", false},
+		{"管理令牌 POST", admin, http.MethodPost, roleAdmin, true},
+	} {
+		req := httptest.NewRequest(tt.method, "/api/index", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.raw)
+		user, ok := bearerUser(req)
+		if ok != tt.ok || user.Role != tt.role {
+			t.Errorf("%s: %v %q，期望 %v %q", tt.name, ok, user.Role, tt.ok, tt.role)
+		}
+	}
+
+	// 接口请求认证失败时返回 JSON 而不是跳转登录页
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/index", nil)
+	AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("未认证的接口请求返回 %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
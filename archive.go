@@ -0,0 +1,400 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 压缩存储的条目需要先解压到内存才能支持随机读取，超过该大小的条目不展示
+	archiveMaxInflate = 64 << 20
+	// 最多同时保留在内存中的解压条目数，限制内存占用上限
+	archiveMaxInflating = 4
+	// 最多缓存的归档目录数量
+	archiveCacheSize = 256
+)
+
+// 作为分类展示的归档文件格式
+var archiveExtensions = map[string]bool{
+	".zip": true,
+	".cbz": true,
+}
+
+var errArchiveEntry = errors.New("归档中没有该文件")
+
+// 归档中的一个媒体文件
+type archiveEntry struct {
+	Name           string // 归档中的路径
+	Method         uint16
+	Offset         int64 // 文件数据在归档中的偏移
+	CompressedSize int64
+	Size           int64
+}
+
+// 归档的中央目录，按归档文件的大小与修改时间判断是否过期
+type archiveIndex struct {
+	size     int64
+	modTime  time.Time
+	entries  []archiveEntry // 按自然顺序排列
+	byName   map[string]int
+	lastUsed time.Time
+}
+
+var (
+	archiveCache   = make(map[string]*archiveIndex)
+	archiveCacheMu sync.Mutex
+	// 解压名额，读取方关闭条目后归还，其余请求等待
+	archiveInflateSlots = make(chan struct{}, archiveMaxInflating)
+)
+
+func isArchiveName(name string) bool {
+	return archiveExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// 路径是否为归档文件，同名目录仍按目录处理
+func isArchiveFile(name string) bool {
+	if !isArchiveName(name) {
+		return false
+	}
+	info, err := imageStore.Stat(name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// 归档分类的展示标题，去掉扩展名
+func archiveTitle(name string) string {
+	if isArchiveName(name) {
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+	return name
+}
+
+// 读取归档的中央目录，归档未变化时使用缓存
+func openArchive(archivePath string) (*archiveIndex, error) {
+	info, err := imageStore.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	archiveCacheMu.Lock()
+	index, ok := archiveCache[archivePath]
+	if ok && index.size == info.Size() && index.modTime.Equal(info.ModTime()) {
+		index.lastUsed = time.Now()
+		archiveCacheMu.Unlock()
+		return index, nil
+	}
+	archiveCacheMu.Unlock()
+
+	index, err = readArchive(archivePath, info)
+	if err != nil {
+		return nil, err
+	}
+	archiveCacheMu.Lock()
+	defer archiveCacheMu.Unlock()
+	if _, ok := archiveCache[archivePath]; !ok && len(archiveCache) >= archiveCacheSize {
+		// 淘汰最久未使用的归档
+		var oldest string
+		for p, cached := range archiveCache {
+			if oldest == "" || cached.lastUsed.Before(archiveCache[oldest].lastUsed) {
+				oldest = p
+			}
+		}
+		delete(archiveCache, oldest)
+	}
+	archiveCache[archivePath] = index
+	return index, nil
+}
+
+func readArchive(archivePath string, info fs.FileInfo) (*archiveIndex, error) {
+	file, err := openStorageFile(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	index := &archiveIndex{
+		size:     info.Size(),
+		modTime:  info.ModTime(),
+		byName:   make(map[string]int),
+		lastUsed: time.Now(),
+	}
+	for _, f := range reader.File {
+		name, ok := archiveEntryName(f)
+		if !ok || mediaKind(name) == "" {
+			continue
+		}
+		// 只支持未加密的存储与 Deflate 条目
+		if f.Flags&0x1 != 0 || f.Method != zip.Store && f.Method != zip.Deflate {
+			continue
+		}
+		if f.Method == zip.Deflate && f.UncompressedSize64 > archiveMaxInflate {
+			continue
+		}
+		offset, err := f.DataOffset()
+		if err != nil {
+			continue
+		}
+		index.entries = append(index.entries, archiveEntry{
+			Name:           name,
+			Method:         f.Method,
+			Offset:         offset,
+			CompressedSize: int64(f.CompressedSize64),
+			Size:           int64(f.UncompressedSize64),
+		})
+	}
+	slices.SortFunc(index.entries, func(a, b archiveEntry) int {
+		return naturalCompare(a.Name, b.Name)
+	})
+	for i, entry := range index.entries {
+		index.byName[entry.Name] = i
+	}
+	return index, nil
+}
+
+// 校验条目名称，跳过目录、隐藏文件、macOS 生成的元数据以及指向归档外部的路径。
+// 非 UTF-8 编码的名称无法在地址中使用，同样跳过
+func archiveEntryName(f *zip.File) (string, bool) {
+	if f.FileInfo().IsDir() || !utf8.ValidString(f.Name) {
+		return "", false
+	}
+	name := path.Clean(strings.ReplaceAll(f.Name, "\\", "/"))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if hiddenName(segment) || segment == "__MACOSX" {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+func (index *archiveIndex) entry(name string) (archiveEntry, bool) {
+	i, ok := index.byName[name]
+	if !ok {
+		return archiveEntry{}, false
+	}
+	return index.entries[i], true
+}
+
+// 第一个符合条件的条目，用作封面
+func (index *archiveIndex) cover(accept func(kind string) bool) (string, bool) {
+	for _, entry := range index.entries {
+		if accept(mediaKind(entry.Name)) {
+			return entry.Name, true
+		}
+	}
+	return "", false
+}
+
+// 打开归档中的条目，存储条目直接读取归档文件，Deflate 条目解压到内存。
+// 解压的条目在关闭前一直占用一个解压名额
+// 等待名额时 ctx 结束则放弃打开
+func (index *archiveIndex) open(ctx context.Context, archivePath string, entry archiveEntry) (MediaFile, error) {
+	file, err := openStorageFile(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Method == zip.Store {
+		return &archiveEntryReader{readSeekerAt: io.NewSectionReader(file, entry.Offset, entry.Size), file: file}, nil
+	}
+	defer file.Close()
+	select {
+	case archiveInflateSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := sync.OnceFunc(func() { <-archiveInflateSlots })
+	inflater := flate.NewReader(io.NewSectionReader(file, entry.Offset, entry.CompressedSize))
+	defer inflater.Close()
+	data, err := io.ReadAll(io.LimitReader(inflater, entry.Size))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &archiveEntryReader{readSeekerAt: bytes.NewReader(data), release: release}, nil
+}
+
+type readSeekerAt interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+type archiveEntryReader struct {
+	readSeekerAt
+	file    io.Closer
+	release func() // 归还解压名额
+}
+
+func (r *archiveEntryReader) Close() error {
+	if r.release != nil {
+		r.release()
+	}
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// 拆分指向归档内条目的路径，如 Comics/vol1.cbz/001.jpg
+func splitArchivePath(filePath string) (string, string, bool) {
+	segments := strings.Split(filePath, "/")
+	for i := 0; i < len(segments)-1; i++ {
+		if !isArchiveName(segments[i]) {
+			continue
+		}
+		archivePath := strings.Join(segments[:i+1], "/")
+		if isArchiveFile(archivePath) {
+			return archivePath, strings.Join(segments[i+1:], "/"), true
+		}
+	}
+	return "", "", false
+}
+
+// 读取媒体文件的大小与修改时间，归档中的条目使用归档文件的修改时间，归档更新后缓存随之失效
+func statMedia(filePath string) (int64, time.Time, error) {
+	if archivePath, name, ok := splitArchivePath(filePath); ok {
+		index, err := openArchive(archivePath)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		entry, ok := index.entry(name)
+		if !ok {
+			return 0, time.Time{}, errArchiveEntry
+		}
+		return entry.Size, index.modTime, nil
+	}
+	info, err := imageStore.Stat(filePath)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, time.Time{}, fs.ErrNotExist
+	}
+	return info.Size(), info.ModTime(), nil
+}
+
+// 打开的媒体文件，普通文件与归档中的条目都支持随机读取
+type MediaFile interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// 打开媒体文件，路径可以指向归档中的条目
+func openMedia(filePath string) (MediaFile, error) {
+	return openMediaContext(context.Background(), filePath)
+}
+
+// 打开媒体文件，解压归档条目需要等待名额时随 ctx 结束而放弃，用于响应请求
+func openMediaContext(ctx context.Context, filePath string) (MediaFile, error) {
+	archivePath, name, ok := splitArchivePath(filePath)
+	if !ok {
+		return openStorageFile(filePath)
+	}
+	index, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := index.entry(name)
+	if !ok {
+		return nil, errArchiveEntry
+	}
+	return index.open(ctx, archivePath, entry)
+}
+
+// 返回媒体文件内容，支持 Range 请求
+func serveMedia(w http.ResponseWriter, r *http.Request, filePath string) {
+	_, modTime, err := statMedia(filePath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	content, err := openMediaContext(r.Context(), filePath)
+	if ctxErr := r.Context().Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+		// 解压名额已满，等待期间请求被取消或超时
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "服务器繁忙，请稍后再试", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Printf("无法读取文件 %s: %v", filePath, err)
+		http.Error(w, "无法读取文件", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+	http.ServeContent(w, r, path.Base(filePath), modTime, content)
+}
+
+// 直接读取归档中的条目，其余请求交给文件服务处理
+func archiveMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filePath := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if _, _, ok := splitArchivePath(filePath); ok {
+			serveMedia(w, r, filePath)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 扫描归档分类，归档无法读取或其中没有媒体文件时返回false
+func scanArchive(name string) (Category, bool) {
+	index, err := openArchive(name)
+	if err != nil {
+		log.Printf("无法读取归档 %s: %v", name, err)
+		return Category{}, false
+	}
+	cover, ok := index.cover(func(kind string) bool { return kind == mediaImage })
+	if !ok {
+		cover, ok = index.cover(func(kind string) bool { return kind != "" })
+	}
+	if !ok {
+		return Category{}, false
+	}
+	return Category{
+		Name:        path.Base(name),
+		Path:        name,
+		EncodedName: encodeCategoryPath(name),
+		CoverImage:  cover,
+		Title:       archiveTitle(path.Base(name)),
+		ImageCount:  len(index.entries),
+		modTime:     index.modTime,
+	}, true
+}
+
+// 列出归档中的媒体文件
+func readArchiveDir(name string) ([]Image, error) {
+	index, err := openArchive(name)
+	if err != nil {
+		return nil, err
+	}
+	imageList := make([]Image, 0, len(index.entries))
+	for _, entry := range index.entries {
+		media, _ := lookupMedia(entry.Name)
+		imageList = append(imageList, Image{
+			Name:        entry.Name,
+			EncodedName: encodeCategoryPath(entry.Name),
+			Type:        strings.TrimPrefix(strings.ToLower(path.Ext(entry.Name)), "."),
+			Kind:        media.Kind,
+			Mime:        media.Mime,
+			Size:        entry.Size,
+			ModTime:     index.modTime,
+		})
+	}
+	return imageList, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestZip(t *testing.T, name string, entries map[string][]byte, method uint16) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for entryName, content := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: entryName, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeImageFile(t, name, buf.Bytes())
+}
+
+// 同时解压到内存的条目数受限，关闭条目后归还名额
+func TestArchiveInflateSlots(t *testing.T) {
+	writeTestZip(t, "InflateSlots.zip", map[string][]byte{"a.jpg": testContent(1000)}, zip.Deflate)
+	var readers []io.ReadSeekCloser
+	for range archiveMaxInflating {
+		r, err := openMedia("InflateSlots.zip/a.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		readers = append(readers, r)
+	}
+
+	opened := make(chan io.ReadSeekCloser)
+	go func() {
+		r, err := openMedia("InflateSlots.zip/a.jpg")
+		if err != nil {
+			t.Error(err)
+		}
+		opened <- r
+	}()
+	select {
+	case <-opened:
+		t.Fatal("解压名额用尽时应等待")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	readers[0].Close()
+	readers[0].Close() // 重复关闭不应多归还名额
+	select {
+	case r := <-opened:
+		readers[0] = r
+	case <-time.After(time.Second):
+		t.Fatal("关闭条目后未归还解压名额")
+	}
+	for _, r := range readers {
+		r.Close()
+	}
+	if n := len(archiveInflateSlots); n != 0 {
+		t.Errorf("全部关闭后仍占用 %d 个名额", n)
+	}
+}
+
+// 解压名额用尽时，等待中的请求结束后返回 503
+func TestServeMediaContextCanceled(t *testing.T) {
+	writeTestZip(t, "InflateBusy.zip", map[string][]byte{"a.jpg": testContent(1000)}, zip.Deflate)
+	var readers []io.ReadSeekCloser
+	for range archiveMaxInflating {
+		r, err := openMedia("InflateBusy.zip/a.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		readers = append(readers, r)
+	}
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	serveMedia(rec, httptest.NewRequest(http.MethodGet, "/images/InflateBusy.zip/a.jpg", nil).WithContext(ctx), "InflateBusy.zip/a.jpg")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("返回 %d，期望 503", rec.Code)
+	}
+}
+
+// 归档中的图片同样读取 EXIF 信息
+func TestReadExifArchive(t *testing.T) {
+	content := testExifJPEG(testExifTIFF([]testExifField{exifShort(exifTagOrientation, 6)}))
+	writeImageFile(t, "Exif/a.jpg", content)
+	writeTestZip(t, "ExifStored.zip", map[string][]byte{"a.jpg": content}, zip.Store)
+	writeTestZip(t, "ExifDeflated.zip", map[string][]byte{"a.jpg": content}, zip.Deflate)
+	for _, filePath := range []string{"Exif/a.jpg", "ExifStored.zip/a.jpg", "ExifDeflated.zip/a.jpg"} {
+		data, err := readExif(filePath)
+		if err != nil || data.Orientation != 6 {
+			t.Errorf("%s: 方向为 %d: %v", filePath, data.Orientation, err)
+		}
+	}
+}
+
+// 归档条目名不受文件系统限制，可能包含引号等字符，链接中使用转义后的名称
+func TestArchiveEntryNamesEscaped(t *testing.T) {
+	name := `ch1/x" onerror=alert(1) #.jpg`
+	writeTestZip(t, "Hostile.zip", map[string][]byte{name: testContent(10)}, zip.Store)
+	images, err := readArchiveDir("Hostile.zip")
+	if err != nil || len(images) != 1 {
+		t.Fatalf("readArchiveDir: %v %v", images, err)
+	}
+	encoded := images[0].EncodedName
+	if strings.ContainsAny(encoded, "\" #") {
+		t.Errorf("EncodedName 未转义: %q", encoded)
+	}
+	if decoded, err := url.PathUnescape(encoded); err != nil || decoded != name {
+		t.Errorf("EncodedName 无法还原: %q", encoded)
+	}
+
+	for _, dynamic := range []string{"false", "true"} {
+		config.Dynamic = dynamic
+		rec := httptest.NewRecorder()
+		categoryHandler(rec, httptest.NewRequest(http.MethodGet, "/category/Hostile.zip", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("dynamic=%s: 返回 %d", dynamic, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), `x" onerror`) {
+			t.Errorf("dynamic=%s: 页面中包含未转义的文件名", dynamic)
+		}
+	}
+	config.Dynamic = ""
+}
@@ -1,60 +1,325 @@
 package main
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// 认证令牌载荷
+type authClaims struct {
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	Expires   int64  `json:"exp"`
+}
+
+var errInvalidToken = errors.New("无效的认证令牌")
+
+type ctxKey int
+
+const userCtxKey ctxKey = iota
+
 // 认证中间件
 func AuthMiddleware(next http.Handler) http.Handler {
-	if config.Secure != "false" || config.LinuxdoEnable != "false" {
+	if authEnabled() {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			cookie, err := r.Cookie("auth")
-			// log.Printf("请求路径: %s, Cookie状态: %+v, 错误信息: %v", r.URL.Path, cookie, err)
-
-			if err != nil || !verifyCookie(cookie) {
+			var user UserInfo
+			var ok bool
+			switch {
+			case hasAPIToken(r):
+				user, ok = bearerUser(r)
+			case proxyAuthEnabled():
+				user, ok = proxyUser(r)
+			default:
+				user, ok = authenticate(r)
+			}
+			if !ok && !hasAPIToken(r) {
+				// 分享链接访客只能访问对应分类
+				user, ok = shareUser(r)
+			}
+			if !ok {
+				if isAPIRequest(r) {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+					return
+				}
+				if proxyAuthEnabled() {
+					http.Error(w, "请通过认证代理访问", http.StatusForbidden)
+					return
+				}
 				// log.Printf("验证失败，跳转登录页面")
 				http.Redirect(w, r, "/login", http.StatusFound)
 				return
 			}
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userCtxKey, user)))
 		})
 	}
 	return next
 }
 
-// 验证cookie有效性
-func verifyCookie(cookie *http.Cookie) bool {
-	return cookie != nil && strings.Contains(cookie.Value, "authenticated")
-	// return cookie != nil && cookie.Value == "authenticated"
+// 校验认证cookie并还原登录用户
+func authenticate(r *http.Request) (UserInfo, bool) {
+	cookie, err := r.Cookie("auth")
+	// log.Printf("请求路径: %s, Cookie状态: %+v, 错误信息: %v", r.URL.Path, cookie, err)
+	if err != nil {
+		return UserInfo{}, false
+	}
+	claims, ok := verifyCookie(cookie)
+	if !ok {
+		return UserInfo{}, false
+	}
+	return resolveUser(r, claims.Subject)
+}
+
+// 验证cookie有效性（签名、有效期以及会话是否已被撤销）
+func verifyCookie(cookie *http.Cookie) (*authClaims, bool) {
+	if cookie == nil {
+		return nil, false
+	}
+	claims, err := parseAuthToken(cookie.Value)
+	if err != nil || !sessionActive(claims.SessionID, claims.Subject) {
+		return nil, false
+	}
+	return claims, true
+}
+
+// 根据令牌主体还原当前用户，本地账号被删除后其令牌随即失效
+func resolveUser(r *http.Request, subject string) (UserInfo, bool) {
+	provider, name, _ := strings.Cut(subject, ":")
+	switch provider {
+	case "password":
+		// 单一访问密码的持有者即站点所有者；强制两步验证后，此前用访问密码签发的令牌随即失效
+		if config.AdminRequire2FA == "true" {
+			return UserInfo{}, false
+		}
+		return UserInfo{Role: roleAdmin, Provider: provider, Subject: subject}, true
+	case "user":
+		account, ok := findAccount(name)
+		if !ok {
+			return UserInfo{}, false
+		}
+		if totpRequired(account) && account.TOTPSecret == "" {
+			// 开启强制两步验证前签发的令牌，需重新登录完成绑定
+			return UserInfo{}, false
+		}
+		return UserInfo{Username: account.Username, Role: account.Role, Provider: provider, Subject: subject}, true
+	case "proxy":
+		if !proxyAuthEnabled() {
+			return UserInfo{}, false
+		}
+		return UserInfo{Username: name, Role: proxyUserRole(name), Provider: provider, Subject: subject}, true
+	case "oauth":
+		// 主体格式为 oauth:提供方:用户名，提供方被移除后令牌随即失效
+		providerName, username, _ := strings.Cut(name, ":")
+		p, ok := findProvider(providerName)
+		if !ok {
+			return UserInfo{}, false
+		}
+		session, _ := store.Get(r, "session-name")
+		avatar, _ := session.Values["avatar"].(string)
+		return UserInfo{
+			Username:     username,
+			AvatarURL:    avatar,
+			Role:         roleViewer,
+			Provider:     p.Name,
+			ProviderName: p.DisplayName,
+			Subject:      subject,
+		}, true
+	}
+	return UserInfo{}, false
+}
+
+// 是否启用登录认证
+func authEnabled() bool {
+	return config.Secure != "false" || len(oauthProviders) > 0 || proxyAuthEnabled()
+}
+
+// 获取当前请求的登录用户，未启用认证时返回空用户
+func currentUser(r *http.Request) UserInfo {
+	user, _ := r.Context().Value(userCtxKey).(UserInfo)
+	return user
+}
+
+// 签发认证令牌，格式为 base64(载荷).base64(HMAC-SHA256签名)
+func signAuthToken(subject, sessionID string, ttl time.Duration) string {
+	now := time.Now()
+	payload, _ := json.Marshal(authClaims{
+		Subject:   subject,
+		SessionID: sessionID,
+		IssuedAt:  now.Unix(),
+		Expires:   now.Add(ttl).Unix(),
+	})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(authSignature(encoded))
+}
+
+// 解析并校验认证令牌
+func parseAuthToken(token string) (*authClaims, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, authSignature(encoded)) {
+		return nil, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	var claims authClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errInvalidToken
+	}
+	if claims.Subject == "" || time.Now().Unix() >= claims.Expires {
+		return nil, errInvalidToken
+	}
+	return &claims, nil
+}
+
+func authSignature(data string) []byte {
+	mac := hmac.New(sha256.New, []byte(config.AuthSecret))
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// 登记会话并写入认证cookie
+func setAuthCookie(w http.ResponseWriter, r *http.Request, subject string) {
+	maxAge := cookieMaxAge()
+	ttl := time.Duration(maxAge) * time.Second
+	record := createSessionRecord(r, subject, ttl)
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth",
+		Value:    signAuthToken(subject, record.ID, ttl),
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Path:     "/",
+		Domain:   config.CookieDomain,
+		Secure:   cookieSecure(),
+		SameSite: cookieSameSite(),
+	})
+}
+
+// 退出登录：撤销服务端会话并清除认证cookie与第三方登录会话。
+// 只接受带 CSRF 令牌的 POST 请求，避免其他页面通过图片等链接使用户退出
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cookie, err := r.Cookie("auth"); err == nil {
+		if claims, err := parseAuthToken(cookie.Value); err == nil {
+			revokeSession(claims.SessionID)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth",
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Path:     "/",
+		Domain:   config.CookieDomain,
+		Secure:   cookieSecure(),
+		SameSite: cookieSameSite(),
+	})
+	session, _ := store.Get(r, "session-name")
+	session.Values = make(map[interface{}]interface{})
+	session.Options.MaxAge = -1
+	session.Save(r, w)
+
+	if proxyAuthEnabled() {
+		// 由认证代理负责退出登录
+		target := config.ProxyLogoutURL
+		if target == "" {
+			target = "/"
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
 // 登录处理器
 func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if proxyAuthEnabled() {
+		// 代理认证模式下不使用内置登录页
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
 	if r.Method == http.MethodPost {
+		if config.Secure == "false" {
+			http.Error(w, "未开启密码登录", http.StatusForbidden)
+			return
+		}
+		ip := clientIP(r)
+		keys := []string{"ip:" + ip}
+		username := strings.TrimSpace(r.FormValue("username"))
+		if multiUser() {
+			keys = append(keys, "user:"+username)
+		}
+		if wait := loginLockedFor(keys...); wait > 0 {
+			retry := int(wait.Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			http.Error(w, fmt.Sprintf("登录失败次数过多，请在 %d 秒后重试", retry), http.StatusTooManyRequests)
+			return
+		}
+
+		if multiUser() {
+			// 多用户模式：校验用户名与密码哈希
+			if account, ok := checkPassword(username, r.FormValue("password")); ok {
+				if account.TOTPSecret != "" || totpRequired(account) {
+					// 需要两步验证，通过后才签发认证cookie
+					setPendingTOTPUser(w, r, account.Username)
+					http.Redirect(w, r, "/login/2fa", http.StatusFound)
+					return
+				}
+				resetLoginFailures(keys...)
+				setAuthCookie(w, r, "user:"+account.Username)
+				http.Redirect(w, r, "/", http.StatusFound)
+				return
+			}
+			recordLoginFailure(keys...)
+			log.Printf("用户 %s 登录失败，来源IP：%s", username, ip)
+			http.Error(w, "用户名或密码错误", http.StatusUnauthorized)
+			return
+		}
 		// 验证密码
-		log.Printf("输入密码：%s，正确密码：%s", r.FormValue("password"), config.Password)
-		if r.FormValue("password") == config.Password {
-			// 设置认证cookie（1小时有效期）
-			http.SetCookie(w, &http.Cookie{
-				Name:     "auth",
-				Value:    "authenticated",
-				MaxAge:   3600, // 使用秒数设置有效期（1小时）
-				HttpOnly: true,
-				Path:     "/",
-				Secure:   true,                 // 开发环境可设为false，生产环境必须设为true
-				SameSite: http.SameSiteLaxMode, // 添加SameSite属性
-			})
+		if subtle.ConstantTimeCompare([]byte(r.FormValue("password")), []byte(config.Password)) == 1 {
+			resetLoginFailures(keys...)
+			// 设置认证cookie
+			setAuthCookie(w, r, "password")
 			http.Redirect(w, r, "/", http.StatusFound)
 			return
 		}
+		recordLoginFailure(keys...)
+		log.Printf("密码登录失败，来源IP：%s", ip)
 		http.Error(w, "密码错误", http.StatusUnauthorized)
 		return
 	}
 
 	// 显示登录表单
-	tmpl := template.Must(template.New("login").Parse(loginTemplate))
-	tmpl.Execute(w, config)
+	data := struct {
+		Config    Config
+		MultiUser bool
+		Providers []OAuthProvider
+	}{
+		Config:    config,
+		MultiUser: multiUser(),
+		Providers: oauthProviders,
+	}
+	tmpl := template.Must(template.New("login").Funcs(csrfFuncs(w, r)).Parse(loginTemplate))
+	tmpl.Execute(w, data)
 }
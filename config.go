@@ -1,35 +1,99 @@
 package main
 
+import "time"
+
 type Config struct {
-	ImageDir            string `yaml:"image_dir"`
-	Secure              string `yaml:"secure"`
-	Password            string `yaml:"password"`
-	Port                string `yaml:"port"`
-	Title               string `yaml:"title"`
-	Icon                string `yaml:"icon"`
-	Dynamic             string `yaml:"dynamic"`
-	WebAdderss          string `yaml:"web_adderss"`
-	LinuxdoEnable       string `yaml:"linuxdo_enable"`
-	LinuxdoClientId     string `yaml:"linuxdo_client_id"`
-	LinuxdoClientSecret string `yaml:"linuxdo_client_secret"`
+	ImageDir             string          `yaml:"image_dir"`
+	Secure               string          `yaml:"secure"`
+	Password             string          `yaml:"password"`
+	Port                 string          `yaml:"port"`
+	Title                string          `yaml:"title"`
+	Icon                 string          `yaml:"icon"`
+	Dynamic              string          `yaml:"dynamic"`
+	WebAdderss           string          `yaml:"web_adderss"`
+	LinuxdoEnable        string          `yaml:"linuxdo_enable"`
+	LinuxdoClientId      string          `yaml:"linuxdo_client_id"`
+	LinuxdoClientSecret  string          `yaml:"linuxdo_client_secret"`
+	LinuxdoMinTrustLevel int             `yaml:"linuxdo_min_trust_level"`
+	LinuxdoAllowUsers    []string        `yaml:"linuxdo_allow_users"`
+	LinuxdoDenyUsers     []string        `yaml:"linuxdo_deny_users"`
+	AuthSecret           string          `yaml:"auth_secret"`
+	SessionKeys          []SessionKey    `yaml:"session_keys"`
+	CookieSecure         string          `yaml:"cookie_secure"`
+	CookieSameSite       string          `yaml:"cookie_samesite"`
+	CookieMaxAge         int             `yaml:"cookie_max_age"`
+	CookieDomain         string          `yaml:"cookie_domain"`
+	CategoryACL          []CategoryACL   `yaml:"category_acl"`
+	OAuthProviders       []OAuthProvider `yaml:"oauth_providers"`
+	LoginMaxAttempts     int             `yaml:"login_max_attempts"`
+	LoginLockoutSeconds  int             `yaml:"login_lockout_seconds"`
+	AdminRequire2FA      string          `yaml:"admin_require_2fa"`
+	AuthMode             string          `yaml:"auth_mode"`
+	ProxyHeader          string          `yaml:"proxy_header"`
+	TrustedProxies       []string        `yaml:"trusted_proxies"`
+	ProxyAdminUsers      []string        `yaml:"proxy_admin_users"`
+	ProxyLogoutURL       string          `yaml:"proxy_logout_url"`
+	Watch                string          `yaml:"watch"`
+	WatchInterval        int             `yaml:"watch_interval"`
+	CategorySort         string          `yaml:"category_sort"`
+	CategoryOrder        string          `yaml:"category_order"`
+	ImageSort            string          `yaml:"image_sort"`
+	ImageOrder           string          `yaml:"image_order"`
+	CacheDir             string          `yaml:"cache_dir"`
+	ThumbWorkers         int             `yaml:"thumb_workers"`
+	MediaKinds           []string        `yaml:"media_kinds"`
+	MediaTypes           []MediaTypeRule `yaml:"media_types"`
+	DownloadConcurrent   int             `yaml:"download_concurrent"`
+	DownloadMaxSize      int             `yaml:"download_max_size"`
+	Storage              string          `yaml:"storage"`
+	S3                   S3Config        `yaml:"s3"`
 }
 
-var config = Config{}
+// 兼容 S3 协议的对象存储配置
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"` // 如 https://s3.amazonaws.com、http://127.0.0.1:9000
+	Region    string `yaml:"region"`   // 默认 us-east-1
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"` // 图片所在的目录，默认为存储桶根目录
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	PathStyle string `yaml:"path_style"` // "true" 时使用 endpoint/bucket 形式的地址，MinIO 通常需要开启
+}
 
-var categoryCache []Category
+// 额外支持的文件格式，也可覆盖内置格式的类型与 MIME 类型
+type MediaTypeRule struct {
+	Ext  string `yaml:"ext"`  // 扩展名，如 .mkv
+	Kind string `yaml:"kind"` // image、video 或 audio
+	Mime string `yaml:"mime"` // 未设置时按扩展名推断
+}
 
-type Category struct {
-	Name        string
-	EncodedName string
-	CoverImage  string
+// 分类访问规则，满足任一名单即可访问
+type CategoryACL struct {
+	Category     string   `yaml:"category"`
+	Users        []string `yaml:"users"`
+	Roles        []string `yaml:"roles"`
+	LinuxdoUsers []string `yaml:"linuxdo_users"`
+	OAuthUsers   []string `yaml:"oauth_users"` // 格式为 提供方:用户名
 }
 
-var imageExtensions = map[string]bool{
-	".jpg":  true,
-	".jpeg": true,
-	".png":  true,
-	".gif":  true,
-	".webp": true,
-	".svg":  true,
-	".ico":  true,
+// 会话cookie密钥对，第一组用于签发，其余仅用于校验以支持密钥轮换
+type SessionKey struct {
+	HashKey  string `yaml:"hash_key"`
+	BlockKey string `yaml:"block_key"`
+}
+
+var config = Config{}
+
+type Category struct {
+	Name        string // 目录名
+	Path        string // 相对图片目录的完整路径，如 Trips/2024/Japan
+	EncodedName string // 转义后的完整路径
+	CoverImage  string // 封面图片，相对该分类目录的路径，可能位于子目录中
+	Title       string // 展示标题，默认为目录名
+	Description string
+	Tags        []string
+	SortKey     string
+	Hidden      bool
+	ImageCount  int // 包括子分类在内的图片、视频与音频数量
+	modTime     time.Time
 }
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDownloadConcurrent = 2
+	defaultDownloadMaxSize    = 4096 // MB
+)
+
+// 限制同时进行的打包下载数量
+var downloadSlots chan struct{}
+
+func initDownloads() {
+	n := config.DownloadConcurrent
+	if n <= 0 {
+		n = defaultDownloadConcurrent
+	}
+	downloadSlots = make(chan struct{}, n)
+}
+
+// 单次下载的文件总大小上限，单位字节
+func downloadMaxSize() int64 {
+	size := config.DownloadMaxSize
+	if size <= 0 {
+		size = defaultDownloadMaxSize
+	}
+	return int64(size) << 20
+}
+
+// 压缩包中的一个文件
+type downloadEntry struct {
+	name    string // 压缩包中的路径，相对分类目录
+	path    string // 可以指向归档中的条目
+	size    int64
+	modTime time.Time
+}
+
+// 收集分类目录及未隐藏的子目录中的媒体文件与归档，跳过以点开头的文件与目录；
+// 分类本身是归档时收集其中的媒体文件
+func collectDownload(dirPath string) ([]downloadEntry, error) {
+	var entries []downloadEntry
+	if isArchiveFile(dirPath) {
+		index, err := openArchive(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range index.entries {
+			entries = append(entries, downloadEntry{
+				name:    entry.Name,
+				path:    path.Join(dirPath, entry.Name),
+				size:    entry.Size,
+				modTime: index.modTime,
+			})
+		}
+		return entries, nil
+	}
+	err := fs.WalkDir(imageStore, dirPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dirPath {
+				return err
+			}
+			return nil
+		}
+		if p != dirPath && hiddenName(d.Name()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if p != dirPath && loadAlbumMeta(p).Hidden {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || mediaKind(d.Name()) == "" && !isArchiveName(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel := strings.TrimPrefix(p, dirPath+"/")
+		entries = append(entries, downloadEntry{name: rel, path: p, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return entries, err
+}
+
+// 打包下载分类，GET 下载全部媒体文件，POST 时只下载表单 name 字段中列出的文件
+func downloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	encoded, ok := strings.CutSuffix(r.URL.Path[len("/download/"):], ".zip")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	category, ok := resolveCategoryPath(encoded)
+	if !ok {
+		http.Error(w, "无效路径", http.StatusBadRequest)
+		return
+	}
+	if !categoryAllowed(currentUser(r), category) {
+		http.NotFound(w, r)
+		return
+	}
+	if info, err := imageStore.Stat(category); err != nil || !info.IsDir() && !isArchiveFile(category) {
+		http.NotFound(w, r)
+		return
+	}
+
+	entries, err := collectDownload(category)
+	if err != nil {
+		http.Error(w, "无法读取图片目录", http.StatusInternalServerError)
+		return
+	}
+	// 子分类可能另有访问规则，无权访问的部分不打包
+	if len(config.CategoryACL) > 0 {
+		user := currentUser(r)
+		entries = slices.DeleteFunc(entries, func(entry downloadEntry) bool {
+			return !categoryAllowed(user, entry.path)
+		})
+	}
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "无效请求", http.StatusBadRequest)
+			return
+		}
+		names := r.PostForm["name"]
+		if len(names) == 0 {
+			http.Error(w, "未选择要下载的文件", http.StatusBadRequest)
+			return
+		}
+		entries, ok = selectDownload(entries, names)
+		if !ok {
+			http.Error(w, "选择的文件不存在", http.StatusBadRequest)
+			return
+		}
+	}
+	if len(entries) == 0 {
+		http.Error(w, "没有可下载的文件", http.StatusNotFound)
+		return
+	}
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+	if total > downloadMaxSize() {
+		http.Error(w, "文件总大小超过下载限制", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	select {
+	case downloadSlots <- struct{}{}:
+		defer func() { <-downloadSlots }()
+	default:
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "同时下载的人数过多，请稍后再试", http.StatusTooManyRequests)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": path.Base(category) + ".zip",
+	}))
+	// 图片与视频本身已经压缩，使用存储模式直接写出，不占用CPU也不需要临时文件
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		if err := writeZipEntry(zw, entry); err != nil {
+			// 响应已经开始，只能中断连接
+			log.Printf("打包下载 %s 中断: %v", category, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("打包下载 %s 中断: %v", category, err)
+	}
+}
+
+// 按名称筛选文件，有任一名称不存在时返回false
+func selectDownload(entries []downloadEntry, names []string) ([]downloadEntry, bool) {
+	byName := make(map[string]downloadEntry, len(entries))
+	for _, entry := range entries {
+		byName[entry.name] = entry
+	}
+	selected := make([]downloadEntry, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		entry, ok := byName[name]
+		if !ok {
+			return nil, false
+		}
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, entry)
+		}
+	}
+	return selected, true
+}
+
+func writeZipEntry(zw *zip.Writer, entry downloadEntry) error {
+	file, err := openMedia(entry.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dst, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     entry.name,
+		Method:   zip.Store,
+		Modified: entry.modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, file)
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// 读取下载的压缩包，返回各文件的内容
+func readDownload(t *testing.T, rec *httptest.ResponseRecorder) map[string][]byte {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("下载返回 %d: %s", rec.Code, rec.Body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = io.ReadAll(r)
+		r.Close()
+	}
+	return files
+}
+
+func TestDownloadCategory(t *testing.T) {
+	writeImageFile(t, "Download/a.jpg", []byte("a"))
+	writeImageFile(t, "Download/notes.txt", []byte("not media"))
+	writeImageFile(t, "Download/.hidden.jpg", []byte("hidden"))
+	writeImageFile(t, "Download/Sub/b.jpg", []byte("b"))
+	writeImageFile(t, "Download/Private/c.jpg", []byte("c"))
+	writeImageFile(t, "Download/Private/album.yaml", []byte("hidden: true\n"))
+
+	rec := httptest.NewRecorder()
+	downloadHandler(rec, httptest.NewRequest(http.MethodGet, "/download/Download.zip", nil))
+	if disposition := rec.Header().Get("Content-Disposition"); !strings.Contains(disposition, `filename=Download.zip`) {
+		t.Errorf("Content-Disposition = %q", disposition)
+	}
+	files := readDownload(t, rec)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"Sub/b.jpg", "a.jpg"}) {
+		t.Errorf("压缩包中的文件为 %v", names)
+	}
+	if string(files["Sub/b.jpg"]) != "b" {
+		t.Errorf("文件内容不正确: %q", files["Sub/b.jpg"])
+	}
+}
+
+// POST 时只下载选中的文件，不存在的文件名返回 400
+func TestDownloadSelected(t *testing.T) {
+	writeImageFile(t, "DownloadPick/a.jpg", []byte("a"))
+	writeImageFile(t, "DownloadPick/b.jpg", []byte("b"))
+	post := func(names ...string) *httptest.ResponseRecorder {
+		form := url.Values{"name": names}
+		req := httptest.NewRequest(http.MethodPost, "/download/DownloadPick.zip", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		downloadHandler(rec, req)
+		return rec
+	}
+
+	files := readDownload(t, post("b.jpg", "b.jpg"))
+	if len(files) != 1 || string(files["b.jpg"]) != "b" {
+		t.Errorf("压缩包中的文件为 %v", files)
+	}
+	for _, names := range [][]string{{"../DownloadPick/a.jpg"}, {"missing.jpg"}, nil} {
+		if rec := post(names...); rec.Code != http.StatusBadRequest {
+			t.Errorf("%v: 返回 %d，期望 400", names, rec.Code)
+		}
+	}
+}
+
+// 超过总大小限制或并发数时拒绝下载
+func TestDownloadLimits(t *testing.T) {
+	writeImageFile(t, "DownloadBig/a.jpg", make([]byte, 2<<20))
+	saved := config.DownloadMaxSize
+	config.DownloadMaxSize = 1
+	t.Cleanup(func() { config.DownloadMaxSize = saved })
+	rec := httptest.NewRecorder()
+	downloadHandler(rec, httptest.NewRequest(http.MethodGet, "/download/DownloadBig.zip", nil))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("超过大小限制返回 %d，期望 413", rec.Code)
+	}
+
+	config.DownloadMaxSize = saved
+	for range cap(downloadSlots) {
+		downloadSlots <- struct{}{}
+	}
+	t.Cleanup(func() {
+		for range cap(downloadSlots) {
+			<-downloadSlots
+		}
+	})
+	rec = httptest.NewRecorder()
+	downloadHandler(rec, httptest.NewRequest(http.MethodGet, "/download/DownloadBig.zip", nil))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("并发数已满时返回 %d", rec.Code)
+	}
+}
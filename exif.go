@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	exifTagMake             = 0x010f
+	exifTagModel            = 0x0110
+	exifTagOrientation      = 0x0112
+	exifTagSoftware         = 0x0131
+	exifTagDateTime         = 0x0132
+	exifTagExifIFD          = 0x8769
+	exifTagExposureTime     = 0x829a
+	exifTagFNumber          = 0x829d
+	exifTagISO              = 0x8827
+	exifTagDateTimeOriginal = 0x9003
+	exifTagExposureBias     = 0x9204
+	exifTagFlash            = 0x9209
+	exifTagFocalLength      = 0x920a
+	exifTagFocalLength35mm  = 0xa405
+	exifTagLensMake         = 0xa433
+	exifTagLensModel        = 0xa434
+)
+
+// IFD 条目的数据类型
+const (
+	exifTypeByte      = 1
+	exifTypeASCII     = 2
+	exifTypeShort     = 3
+	exifTypeLong      = 4
+	exifTypeRational  = 5
+	exifTypeSLong     = 9
+	exifTypeSRational = 10
+)
+
+// 单个 IFD 最多读取的条目数，避免损坏的文件导致大量读取
+const exifMaxEntries = 512
+
+var errNoExif = errors.New("没有 EXIF 信息")
+
+// 图片的 EXIF 信息，接口中只返回存在的字段
+type ExifData struct {
+	Make            string     `json:"make,omitempty"`
+	Model           string     `json:"model,omitempty"`
+	LensMake        string     `json:"lens_make,omitempty"`
+	LensModel       string     `json:"lens_model,omitempty"`
+	Software        string     `json:"software,omitempty"`
+	ExposureTime    string     `json:"exposure_time,omitempty"` // 如 1/250
+	FNumber         float64    `json:"f_number,omitempty"`
+	ISO             int        `json:"iso,omitempty"`
+	FocalLength     float64    `json:"focal_length,omitempty"`      // 毫米
+	FocalLength35mm int        `json:"focal_length_35mm,omitempty"` // 等效35mm焦距
+	ExposureBias    float64    `json:"exposure_bias,omitempty"`     // EV
+	Flash           *bool      `json:"flash,omitempty"`
+	CaptureTime     *time.Time `json:"capture_time,omitempty"`
+	Orientation     int        `json:"orientation,omitempty"` // 1-8，见 EXIF 规范
+}
+
+// TIFF 结构的 EXIF 数据，偏移量均相对 TIFF 头
+type exifReader struct {
+	r     io.ReaderAt
+	size  int64
+	order binary.ByteOrder
+}
+
+type exifEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte // 条目中保存值或偏移量的4个字节
+}
+
+// 从 JPEG 文件中读取 APP1 段里的 EXIF 数据
+func readJPEGExif(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return nil, errNoExif
+	}
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(br, marker[:]); err != nil {
+			return nil, errNoExif
+		}
+		if marker[0] != 0xff {
+			return nil, errNoExif
+		}
+		// 图像数据开始后不会再出现 EXIF
+		if marker[1] == 0xda || marker[1] == 0xd9 {
+			return nil, errNoExif
+		}
+		var size uint16
+		if err := binary.Read(br, binary.BigEndian, &size); err != nil || size < 2 {
+			return nil, errNoExif
+		}
+		if marker[1] != 0xe1 {
+			if _, err := br.Discard(int(size) - 2); err != nil {
+				return nil, errNoExif
+			}
+			continue
+		}
+		segment := make([]byte, size-2)
+		if _, err := io.ReadFull(br, segment); err != nil {
+			return nil, errNoExif
+		}
+		if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+	}
+}
+
+func newExifReader(r io.ReaderAt, size int64) (*exifReader, error) {
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, errNoExif
+	}
+	x := &exifReader{r: r, size: size}
+	switch string(header[:2]) {
+	case "II":
+		x.order = binary.LittleEndian
+	case "MM":
+		x.order = binary.BigEndian
+	default:
+		return nil, errNoExif
+	}
+	if x.order.Uint16(header[2:4]) != 42 {
+		return nil, errNoExif
+	}
+	return x, nil
+}
+
+// 打开图片中的 EXIF 数据，支持 JPEG 以及 TIFF 结构的文件（TIFF、DNG 及多数相机 RAW 格式）
+func openExif(r io.ReaderAt, size int64) (*exifReader, error) {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return nil, errNoExif
+	}
+	switch {
+	case magic[0] == 0xff && magic[1] == 0xd8:
+		data, err := readJPEGExif(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		return newExifReader(bytes.NewReader(data), int64(len(data)))
+	case string(magic[:]) == "II*\x00" || string(magic[:]) == "MM\x00*":
+		return newExifReader(r, size)
+	}
+	return nil, errNoExif
+}
+
+func (x *exifReader) firstIFD() uint32 {
+	var buf [4]byte
+	x.r.ReadAt(buf[:], 4)
+	return x.order.Uint32(buf[:])
+}
+
+// 读取 IFD 中的全部条目
+func (x *exifReader) readIFD(offset uint32) []exifEntry {
+	var countBuf [2]byte
+	if _, err := x.r.ReadAt(countBuf[:], int64(offset)); err != nil {
+		return nil
+	}
+	n := int(x.order.Uint16(countBuf[:]))
+	if n > exifMaxEntries {
+		return nil
+	}
+	buf := make([]byte, n*12)
+	if _, err := x.r.ReadAt(buf, int64(offset)+2); err != nil {
+		return nil
+	}
+	entries := make([]exifEntry, n)
+	for i := range entries {
+		e := buf[i*12:]
+		entries[i] = exifEntry{
+			tag:   x.order.Uint16(e),
+			typ:   x.order.Uint16(e[2:]),
+			count: x.order.Uint32(e[4:]),
+			value: e[8:12],
+		}
+	}
+	return entries
+}
+
+// 条目的原始数据，不超过4字节时直接保存在条目中
+func (x *exifReader) raw(e exifEntry, unit int) []byte {
+	length := int64(e.count) * int64(unit)
+	if length <= 4 {
+		return e.value[:length]
+	}
+	offset := int64(x.order.Uint32(e.value))
+	if length > 1<<16 || offset+length > x.size {
+		return nil
+	}
+	buf := make([]byte, length)
+	if _, err := x.r.ReadAt(buf, offset); err != nil {
+		return nil
+	}
+	return buf
+}
+
+func (x *exifReader) ascii(e exifEntry) string {
+	if e.typ != exifTypeASCII {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(x.raw(e, 1)), "\x00"))
+}
+
+// 读取整数类型的第一个值
+func (x *exifReader) uint(e exifEntry) (int, bool) {
+	switch e.typ {
+	case exifTypeByte:
+		return int(e.value[0]), e.count > 0
+	case exifTypeShort:
+		return int(x.order.Uint16(e.value)), e.count > 0
+	case exifTypeLong, exifTypeSLong:
+		return int(x.order.Uint32(e.value)), e.count > 0
+	}
+	return 0, false
+}
+
+// 读取分数类型的第一个值，返回分子与分母
+func (x *exifReader) rational(e exifEntry) (float64, float64, bool) {
+	if e.typ != exifTypeRational && e.typ != exifTypeSRational || e.count == 0 {
+		return 0, 0, false
+	}
+	data := x.raw(exifEntry{typ: e.typ, count: 1, value: e.value}, 8)
+	if len(data) < 8 {
+		return 0, 0, false
+	}
+	if e.typ == exifTypeSRational {
+		num, den := int32(x.order.Uint32(data)), int32(x.order.Uint32(data[4:]))
+		return float64(num), float64(den), den != 0
+	}
+	num, den := x.order.Uint32(data), x.order.Uint32(data[4:])
+	return float64(num), float64(den), den != 0
+}
+
+func parseExifTime(value string) (time.Time, bool) {
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", value, time.Local)
+	return t, err == nil
+}
+
+func roundTo(value float64, digits int) float64 {
+	p := math.Pow(10, float64(digits))
+	return math.Round(value*p) / p
+}
+
+// 解析常用的 EXIF 字段
+func (x *exifReader) data() ExifData {
+	var data ExifData
+	var modified string
+	var exifIFD uint32
+	for _, e := range x.readIFD(x.firstIFD()) {
+		switch e.tag {
+		case exifTagMake:
+			data.Make = x.ascii(e)
+		case exifTagModel:
+			data.Model = x.ascii(e)
+		case exifTagSoftware:
+			data.Software = x.ascii(e)
+		case exifTagOrientation:
+			if v, ok := x.uint(e); ok && v >= 1 && v <= 8 {
+				data.Orientation = v
+			}
+		case exifTagDateTime:
+			modified = x.ascii(e)
+		case exifTagExifIFD:
+			exifIFD = x.order.Uint32(e.value)
+		}
+	}
+	var original string
+	if exifIFD != 0 {
+		for _, e := range x.readIFD(exifIFD) {
+			switch e.tag {
+			case exifTagExposureTime:
+				if num, den, ok := x.rational(e); ok && num > 0 {
+					if num/den >= 1 {
+						data.ExposureTime = fmt.Sprintf("%g", roundTo(num/den, 1))
+					} else {
+						data.ExposureTime = fmt.Sprintf("1/%g", math.Round(den/num))
+					}
+				}
+			case exifTagFNumber:
+				if num, den, ok := x.rational(e); ok {
+					data.FNumber = roundTo(num/den, 1)
+				}
+			case exifTagISO:
+				data.ISO, _ = x.uint(e)
+			case exifTagDateTimeOriginal:
+				original = x.ascii(e)
+			case exifTagExposureBias:
+				if num, den, ok := x.rational(e); ok {
+					data.ExposureBias = roundTo(num/den, 2)
+				}
+			case exifTagFlash:
+				if v, ok := x.uint(e); ok {
+					fired := v&1 == 1
+					data.Flash = &fired
+				}
+			case exifTagFocalLength:
+				if num, den, ok := x.rational(e); ok {
+					data.FocalLength = roundTo(num/den, 1)
+				}
+			case exifTagFocalLength35mm:
+				data.FocalLength35mm, _ = x.uint(e)
+			case exifTagLensMake:
+				data.LensMake = x.ascii(e)
+			case exifTagLensModel:
+				data.LensModel = x.ascii(e)
+			}
+		}
+	}
+	// 拍摄时间优先使用 DateTimeOriginal，没有时使用 DateTime
+	for _, value := range []string{original, modified} {
+		if t, ok := parseExifTime(value); ok {
+			data.CaptureTime = &t
+			break
+		}
+	}
+	return data
+}
+
+// 读取图片文件的 EXIF 信息，路径可以指向归档中的条目
+func readExif(filePath string) (ExifData, error) {
+	file, err := openMedia(filePath)
+	if err != nil {
+		return ExifData{}, err
+	}
+	defer file.Close()
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return ExifData{}, err
+	}
+	x, err := openExif(file, size)
+	if err != nil {
+		return ExifData{}, err
+	}
+	return x.data(), nil
+}
+
+type exifCacheEntry struct {
+	modTime time.Time
+	data    ExifData
+}
+
+// 按文件路径缓存 EXIF 信息，文件修改后重新读取
+var (
+	exifCache   = make(map[string]exifCacheEntry)
+	exifCacheMu sync.Mutex
+)
+
+func cachedExif(filePath string, modTime time.Time) ExifData {
+	exifCacheMu.Lock()
+	entry, cached := exifCache[filePath]
+	exifCacheMu.Unlock()
+	if cached && entry.modTime.Equal(modTime) {
+		return entry.data
+	}
+
+	data, _ := readExif(filePath)
+	exifCacheMu.Lock()
+	exifCache[filePath] = exifCacheEntry{modTime: modTime, data: data}
+	exifCacheMu.Unlock()
+	return data
+}
+
+// 读取图片的拍摄时间
+func imageCaptureTime(filePath string, modTime time.Time) (time.Time, bool) {
+	data := cachedExif(filePath, modTime)
+	if data.CaptureTime == nil {
+		return time.Time{}, false
+	}
+	return *data.CaptureTime, true
+}
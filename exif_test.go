@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testExifField struct {
+	tag, typ uint16
+	count    uint32
+	data     []byte
+}
+
+func exifASCII(tag uint16, value string) testExifField {
+	return testExifField{tag, exifTypeASCII, uint32(len(value) + 1), append([]byte(value), 0)}
+}
+
+func exifShort(tag uint16, value uint16) testExifField {
+	return testExifField{tag, exifTypeShort, 1, binary.BigEndian.AppendUint16(nil, value)}
+}
+
+func exifLong(tag uint16, value uint32) testExifField {
+	return testExifField{tag, exifTypeLong, 1, binary.BigEndian.AppendUint32(nil, value)}
+}
+
+func exifRational(tag uint16, num, den uint32) testExifField {
+	return testExifField{tag, exifTypeRational, 1, binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, num), den)}
+}
+
+// 拼接大端序的 TIFF 结构，各 IFD 依次排列，超过 4 字节的值放在最后
+func testExifTIFF(ifds ...[]testExifField) []byte {
+	pos := 8
+	for _, ifd := range ifds {
+		pos += 2 + 12*len(ifd) + 4
+	}
+	buf := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	var extra []byte
+	for _, ifd := range ifds {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(ifd)))
+		for _, f := range ifd {
+			buf = binary.BigEndian.AppendUint16(buf, f.tag)
+			buf = binary.BigEndian.AppendUint16(buf, f.typ)
+			buf = binary.BigEndian.AppendUint32(buf, f.count)
+			if len(f.data) <= 4 {
+				buf = append(buf, f.data...)
+				buf = append(buf, make([]byte, 4-len(f.data))...)
+			} else {
+				buf = binary.BigEndian.AppendUint32(buf, uint32(pos+len(extra)))
+				extra = append(extra, f.data...)
+			}
+		}
+		buf = binary.BigEndian.AppendUint32(buf, 0)
+	}
+	return append(buf, extra...)
+}
+
+// 只包含 APP1 段的 JPEG 文件头
+func testExifJPEG(tiff []byte) []byte {
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	content := []byte{0xff, 0xd8, 0xff, 0xe1}
+	content = binary.BigEndian.AppendUint16(content, uint16(len(app1)+2))
+	content = append(content, app1...)
+	return append(content, 0xff, 0xd9)
+}
+
+func testCameraTIFF() []byte {
+	ifd0 := []testExifField{
+		exifASCII(exifTagMake, "Canon"),
+		exifASCII(exifTagModel, "EOS R5"),
+		exifShort(exifTagOrientation, 6),
+		{}, // Exif IFD 的偏移量，见下方
+	}
+	ifd0[3] = exifLong(exifTagExifIFD, uint32(8+2+12*len(ifd0)+4))
+	return testExifTIFF(ifd0, []testExifField{
+		exifRational(exifTagExposureTime, 1, 250),
+		exifRational(exifTagFNumber, 28, 10),
+		exifShort(exifTagISO, 400),
+		exifASCII(exifTagDateTimeOriginal, "2024:05:01 10:20:30"),
+		exifRational(exifTagFocalLength, 50, 1),
+	})
+}
+
+func TestReadExif(t *testing.T) {
+	writeImageFile(t, "ExifCamera/a.jpg", testExifJPEG(testCameraTIFF()))
+	writeImageFile(t, "ExifCamera/b.dng", testCameraTIFF())
+	writeImageFile(t, "ExifCamera/c.png", testPNG(t, 2, 2, 2, 2))
+	taken := time.Date(2024, 5, 1, 10, 20, 30, 0, time.Local)
+
+	for _, name := range []string{"ExifCamera/a.jpg", "ExifCamera/b.dng"} {
+		data, err := readExif(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if data.Make != "Canon" || data.Model != "EOS R5" || data.Orientation != 6 ||
+			data.ExposureTime != "1/250" || data.FNumber != 2.8 || data.ISO != 400 || data.FocalLength != 50 ||
+			data.CaptureTime == nil || !data.CaptureTime.Equal(taken) {
+			t.Errorf("%s: %+v", name, data)
+		}
+	}
+	if _, err := readExif("ExifCamera/c.png"); !errors.Is(err, errNoExif) {
+		t.Errorf("PNG 返回 %v，期望 errNoExif", err)
+	}
+}
+
+func TestImageJsonExif(t *testing.T) {
+	writeImageFile(t, "ExifCamera/a.jpg", testExifJPEG(testCameraTIFF()))
+	rec := httptest.NewRecorder()
+	imageJson(rec, httptest.NewRequest(http.MethodGet, "/api/image/ExifCamera/a.jpg", nil))
+	var body struct {
+		Category string
+		Exif     ExifData
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("返回 %d: %v", rec.Code, err)
+	}
+	if body.Category != "ExifCamera" || body.Exif.Model != "EOS R5" {
+		t.Errorf("接口返回 %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	imageJson(rec, httptest.NewRequest(http.MethodGet, "/api/image/ExifCamera/missing.jpg", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("不存在的图片返回 %d，期望 404", rec.Code)
+	}
+}
@@ -3,12 +3,17 @@ module lovebai/plist
 go 1.24.0
 
 require (
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/go-resty/resty/v2 v2.16.5
 	github.com/gorilla/sessions v1.4.0
+	github.com/mozillazg/go-pinyin v0.21.0
+	golang.org/x/crypto v0.45.0
+	golang.org/x/image v0.33.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
 	github.com/gorilla/securecookie v1.1.2 // indirect
-	golang.org/x/net v0.33.0 // indirect
+	golang.org/x/net v0.47.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
 )
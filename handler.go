@@ -5,17 +5,41 @@ import (
 	"html/template"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
-	"path/filepath"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type UserInfo struct {
-	Username  string
-	AvatarURL string
+	Username      string
+	AvatarURL     string
+	Role          string
+	Provider      string // 登录方式：password、user 或第三方登录提供方名称
+	ProviderName  string // 第三方登录提供方的显示名称
+	ShareCategory string // 分享链接访客可访问的分类
+	Subject       string // 认证令牌主体，分享链接访客为空
+}
+
+type Image struct {
+	Name        string
+	EncodedName string // 逐段转义的文件名，用于拼接链接地址
+	Type        string // 扩展名，如 jpg、mp4
+	Kind        string // 媒体类型：image、video 或 audio
+	Mime        string
+	Width       int
+	Height      int
+	Size        int64
+	ModTime     time.Time
+	Hash        string    // 文件内容 SHA-256 的前16字节
+	taken       time.Time // 拍摄时间，仅在按拍摄时间排序时读取
+}
+
+// 面包屑导航中的一级分类
+type Breadcrumb struct {
+	Name        string
+	EncodedName string
 }
 
 func loggingMiddleware(next http.Handler) http.Handler {
@@ -28,58 +52,65 @@ func loggingMiddleware(next http.Handler) http.Handler {
 }
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
-	session, _ := store.Get(r, "session-name")
-	var username, avatar string
-	if config.LinuxdoEnable != "false" {
-		var ok bool
-		username, ok = session.Values["username"].(string)
-		if !ok {
-			if config.Password != "" {
-				username = ""
-			} else {
-				http.Redirect(w, r, "/login", http.StatusFound)
-				return
-			}
-		}
-		avatar, ok = session.Values["avatar"].(string)
-		if !ok {
-			if config.Password != "" {
-				avatar = ""
-			} else {
-				http.Redirect(w, r, "/login", http.StatusFound)
-				return
-			}
-		}
-	}
-
-	userInfo := UserInfo{
-		Username:  username,
-		AvatarURL: avatar,
-	}
+	userInfo := currentUser(r)
+	spec := parseCategorySort(r, loadAlbumMeta("."))
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
 
 	if config.Dynamic == "true" {
 		type Tmp struct {
-			Config   Config
-			UserInfo UserInfo
+			Config      Config
+			UserInfo    UserInfo
+			Sort        sortSpec
+			SortOptions []sortOption
+			Query       string
 		}
 		var tmp = Tmp{
-			Config:   config,
-			UserInfo: userInfo,
+			Config:      config,
+			UserInfo:    userInfo,
+			Sort:        spec,
+			SortOptions: categorySortOptions,
+			Query:       query,
 		}
-		tmpl := template.Must(template.New("index").Parse(indexDynamicTemplate))
+		tmpl := template.Must(template.New("index").Funcs(csrfFuncs(w, r)).Parse(indexDynamicTemplate))
 		tmpl.Execute(w, tmp)
 	} else {
 		type Tmp struct {
-			Category []Category
-			Config   Config
-			UserInfo UserInfo
+			Category    []Category
+			Config      Config
+			UserInfo    UserInfo
+			Sort        sortSpec
+			SortOptions []sortOption
+			Query       string
+			Results     []SearchResult
+			Total       int
+			PrevPage    int // 上一页页码，没有时为0
+			NextPage    int // 下一页页码，没有时为0
 		}
 		var tmp = Tmp{
-			Category: categoryCache, // 使用缓存数据
-			Config:   config,
-			UserInfo: userInfo,
+			Config:      config,
+			UserInfo:    userInfo,
+			Sort:        spec,
+			SortOptions: categorySortOptions,
+			Query:       query,
+		}
+		if query != "" {
+			page, err := strconv.Atoi(r.URL.Query().Get("page"))
+			if err != nil || page < 1 {
+				page = 1
+			}
+			results := searchCategories(userInfo, query)
+			current, pages := pageSearchResults(results, page, 40)
+			tmp.Results, tmp.Total = current, len(results)
+			if page > 1 {
+				tmp.PrevPage = min(page-1, pages)
+			}
+			if page < pages {
+				tmp.NextPage = page + 1
+			}
+		} else {
+			tmp.Category = sortCategoryList(visibleCategories(userInfo), spec) // 使用缓存数据
 		}
-		tmpl := template.Must(template.New("index").Parse(indexTemplate))
+		tmpl := template.Must(template.New("index").Funcs(csrfFuncs(w, r)).Parse(indexTemplate))
 		tmpl.Execute(w, tmp)
 	}
 
@@ -88,57 +119,63 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 func categoryHandler(w http.ResponseWriter, r *http.Request) {
 	// category := r.URL.Path[len("/category/"):]
 	// category := filepath.FromSlash(r.URL.Path[len("/category/"):])
-	encodedCategory := filepath.FromSlash(r.URL.Path[len("/category/"):])
-	category, _ := url.PathUnescape(encodedCategory)
-	imagePath := filepath.Join(config.ImageDir, category)
-	cleanImageDir := filepath.Clean(config.ImageDir)
-	absImageDir, _ := filepath.Abs(cleanImageDir)
-	absPath, _ := filepath.Abs(imagePath)
-	if !strings.HasPrefix(absPath, absImageDir) {
+	category, ok := resolveCategoryPath(r.URL.Path[len("/category/"):])
+	if !ok {
 		http.Error(w, "无效路径", http.StatusBadRequest)
 		return
 	}
-	entries, err := os.ReadDir(imagePath)
+	if !categoryAllowed(currentUser(r), category) {
+		http.NotFound(w, r)
+		return
+	}
+	imageList, children, err := readCategoryDir(category)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		http.Error(w, "无法读取图片目录", http.StatusInternalServerError)
 		return
 	}
 
-	type Image struct {
-		Name string
-		Type string
-	}
-
-	var imageList []Image
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		ext := strings.ToLower(filepath.Ext(entry.Name()))
-		if imageExtensions[ext] {
-			imageList = append(imageList, Image{
-				Name: entry.Name(),
-				// 添加类型字段供模板使用（可选）
-				Type: strings.TrimPrefix(ext, "."),
-			})
-		}
-	}
+	meta := loadAlbumMeta(category)
+	spec := parseImageSort(r, meta)
+	sortImages(imageList, category, spec)
 
 	data := struct {
-		Category string
-		Images   []Image
-		Config   Config
+		Category    string
+		Name        string
+		Title       string
+		Description string
+		Tags        []string
+		EncodedName string
+		Breadcrumbs []Breadcrumb
+		Children    []Category
+		Images      []Image
+		Sort        sortSpec
+		SortOptions []sortOption
+		Config      Config
 	}{
-		Category: category,
-		Images:   imageList,
-		Config:   config,
+		Category:    category,
+		Name:        path.Base(category),
+		Title:       meta.title(category),
+		Description: meta.Description,
+		Tags:        meta.Tags,
+		EncodedName: encodeCategoryPath(category),
+		Breadcrumbs: categoryBreadcrumbs(category),
+		Children:    sortCategoryList(allowedCategories(currentUser(r), children), parseCategorySort(r, meta)),
+		Images:      imageList,
+		Sort:        spec,
+		SortOptions: imageSortOptions,
+		Config:      config,
 	}
 
 	if config.Dynamic == "true" {
-		tmpl := template.Must(template.New("category").Parse(categoryDynamicTemplate))
+		tmpl := template.Must(template.New("category").Funcs(csrfFuncs(w, r)).Parse(categoryDynamicTemplate))
 		tmpl.Execute(w, data)
 	} else {
-		tmpl := template.Must(template.New("category").Parse(categoryTemplate))
+		fillImageInfo(data.Images, category)
+		tmpl := template.Must(template.New("category").Funcs(csrfFuncs(w, r)).Parse(categoryTemplate))
 		tmpl.Execute(w, data)
 
 	}
@@ -157,8 +194,10 @@ func indexJson(w http.ResponseWriter, r *http.Request) {
 		limit = 20
 	}
 
-	// 使用缓存的分类信息
-	totalCategories := len(categoryCache)
+	// 使用缓存的分类信息，仅包含当前用户可见的分类
+	spec := parseCategorySort(r, loadAlbumMeta("."))
+	categories := sortCategoryList(visibleCategories(currentUser(r)), spec)
+	totalCategories := len(categories)
 	totalPages := (totalCategories + limit - 1) / limit
 	start := (page - 1) * limit
 	end := start + limit
@@ -168,29 +207,30 @@ func indexJson(w http.ResponseWriter, r *http.Request) {
 	if end > totalCategories {
 		end = totalCategories
 	}
-	currentCategories := categoryCache[start:end]
+	currentCategories := categories[start:end]
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"categories": currentCategories,
 		"page":       page,
 		"limit":      limit,
 		"total":      totalCategories,
 		"pages":      totalPages,
-	})
+	}
+	spec.addTo(response)
+	json.NewEncoder(w).Encode(response)
 }
 
 func categoryJson(w http.ResponseWriter, r *http.Request) {
-	encodedCategory := filepath.FromSlash(r.URL.Path[len("/api/category/"):])
-	category, _ := url.PathUnescape(encodedCategory)
-	imagePath := filepath.Join(config.ImageDir, category)
-	cleanImageDir := filepath.Clean(config.ImageDir)
-	absImageDir, _ := filepath.Abs(cleanImageDir)
-	absPath, _ := filepath.Abs(imagePath)
-	if !strings.HasPrefix(absPath, absImageDir) {
+	category, ok := resolveCategoryPath(r.URL.Path[len("/api/category/"):])
+	if !ok {
 		http.Error(w, "无效路径", http.StatusBadRequest)
 		return
 	}
+	if !categoryAllowed(currentUser(r), category) {
+		http.NotFound(w, r)
+		return
+	}
 
 	// 获取分页参数
 	pageStr := r.URL.Query().Get("page")
@@ -204,30 +244,19 @@ func categoryJson(w http.ResponseWriter, r *http.Request) {
 		limit = 20
 	}
 
-	entries, err := os.ReadDir(imagePath)
+	imageList, children, err := readCategoryDir(category)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		http.Error(w, "无法读取图片目录", http.StatusInternalServerError)
 		return
 	}
 
-	type Image struct {
-		Name string
-		Type string
-	}
-
-	var imageList []Image
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		ext := strings.ToLower(filepath.Ext(entry.Name()))
-		if imageExtensions[ext] {
-			imageList = append(imageList, Image{
-				Name: entry.Name(),
-				Type: strings.TrimPrefix(ext, "."),
-			})
-		}
-	}
+	meta := loadAlbumMeta(category)
+	spec := parseImageSort(r, meta)
+	sortImages(imageList, category, spec)
 
 	totalImages := len(imageList)
 	totalPages := (totalImages + limit - 1) / limit
@@ -240,14 +269,125 @@ func categoryJson(w http.ResponseWriter, r *http.Request) {
 		end = totalImages
 	}
 	currentImages := imageList[start:end]
+	fillImageInfo(currentImages, category)
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"category":    category,
+		"title":       meta.title(category),
+		"description": meta.Description,
+		"tags":        meta.Tags,
+		"breadcrumbs": categoryBreadcrumbs(category),
+		"children":    sortCategoryList(allowedCategories(currentUser(r), children), parseCategorySort(r, meta)),
+		"images":      currentImages,
+		"page":        page,
+		"limit":       limit,
+		"total":       totalImages,
+		"pages":       totalPages,
+	}
+	spec.addTo(response)
+	json.NewEncoder(w).Encode(response)
+}
+
+// 读取分类目录，返回其中的媒体文件以及包含媒体文件且未隐藏的子分类，归档分类没有子分类。
+// 子分类的封面与数量来自缓存，图片目录变化后随所属顶层分类一同刷新
+func readCategoryDir(category string) ([]Image, []Category, error) {
+	if isArchiveFile(category) {
+		imageList, err := readArchiveDir(category)
+		return imageList, []Category{}, err
+	}
+	entries, err := imageStore.ReadDir(category)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imageList := []Image{}
+	children := []Category{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if hiddenName(entry.Name()) {
+				continue
+			}
+			if child, ok := scanChildCategory(category + "/" + entry.Name()); ok && !child.Hidden {
+				children = append(children, child)
+			}
+			continue
+		}
+		if entry.Type().IsRegular() && isArchiveName(entry.Name()) && !hiddenName(entry.Name()) {
+			if child, ok := scanChildCategory(category + "/" + entry.Name()); ok {
+				children = append(children, child)
+			}
+			continue
+		}
+		if media, ok := lookupMedia(entry.Name()); ok {
+			image := Image{
+				Name:        entry.Name(),
+				EncodedName: encodeCategoryPath(entry.Name()),
+				Type:        strings.TrimPrefix(strings.ToLower(path.Ext(entry.Name())), "."),
+				Kind:        media.Kind,
+				Mime:        media.Mime,
+			}
+			if info, err := entry.Info(); err == nil {
+				image.Size = info.Size()
+				image.ModTime = info.ModTime()
+			}
+			imageList = append(imageList, image)
+		}
+	}
+	sortCategories(children)
+	return imageList, children, nil
+}
+
+// 分类路径上的每一级，用于面包屑导航
+func categoryBreadcrumbs(category string) []Breadcrumb {
+	var crumbs []Breadcrumb
+	segments := strings.Split(category, "/")
+	for i := range segments {
+		name := strings.Join(segments[:i+1], "/")
+		meta := loadAlbumMeta(name)
+		crumbs = append(crumbs, Breadcrumb{
+			Name:        meta.title(name),
+			EncodedName: encodeCategoryPath(name),
+		})
+	}
+	return crumbs
+}
+
+// 单个媒体文件的详细信息，包括尺寸、大小与 EXIF
+func imageJson(w http.ResponseWriter, r *http.Request) {
+	rel, ok := resolveCategoryPath(r.URL.Path[len("/api/image/"):])
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "无效路径")
+		return
+	}
+	category, name := path.Split(rel)
+	category = strings.TrimSuffix(category, "/")
+	media, ok := lookupMedia(name)
+	if category == "" || !ok || !categoryAllowed(currentUser(r), category) {
+		writeJSONError(w, http.StatusNotFound, "图片不存在")
+		return
+	}
+	size, modTime, err := statMedia(rel)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "图片不存在")
+		return
+	}
+
+	images := []Image{{
+		Name:        name,
+		EncodedName: encodeCategoryPath(name),
+		Type:        strings.TrimPrefix(strings.ToLower(path.Ext(name)), "."),
+		Kind:        media.Kind,
+		Mime:        media.Mime,
+		Size:        size,
+		ModTime:     modTime,
+	}}
+	fillImageInfo(images, category)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"category": category,
-		"images":   currentImages,
-		"page":     page,
-		"limit":    limit,
-		"total":    totalImages,
-		"pages":    totalPages,
+		"image":    images[0],
+		"exif":     cachedExif(rel, modTime),
 	})
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const imageInfoFileName = "imageinfo.json"
+
+// 合并短时间内的多次修改，浏览新相册时不必每个请求都重写整个缓存文件
+const imageInfoSaveDelay = 10 * time.Second
+
+// 等待计算哈希的图片数量上限，队列已满时下次访问再加入
+const imageHashQueueSize = 4096
+
+// 缓存内容变化时递增，使旧条目失效
+const imageInfoVersion = 2
+
+// 图片的显示尺寸与内容哈希，按文件大小与修改时间判断是否过期
+type imageInfoEntry struct {
+	Version int       `json:"v"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+	Hash    string    `json:"hash"`
+}
+
+type imageHashJob struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+var (
+	imageInfoCache     = make(map[string]imageInfoEntry)
+	imageInfoCacheMu   sync.Mutex
+	imageInfoDirty     bool
+	imageInfoSaveTimer *time.Timer
+	// 哈希需要读取整个文件，在后台逐个计算，不阻塞页面渲染
+	imageHashQueue   = make(chan imageHashJob, imageHashQueueSize)
+	imageHashPending = make(map[string]bool) // 已在队列中的图片，受 imageInfoCacheMu 保护
+)
+
+// 加载图片信息缓存并启动后台哈希计算
+func initImageInfo() {
+	loadImageInfo()
+	go hashImages()
+}
+
+func imageInfoFile() string {
+	return filepath.Join(cacheDir(), imageInfoFileName)
+}
+
+// 加载图片信息缓存。启动时不逐个检查文件，对象存储上每次检查都是一次请求；
+// 条目在使用时由 imageInfo 按大小与修改时间判断是否过期
+func loadImageInfo() {
+	content, err := os.ReadFile(imageInfoFile())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("无法读取图片信息缓存 %s: %v", imageInfoFile(), err)
+		return
+	}
+	var entries map[string]imageInfoEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		log.Printf("无法解析图片信息缓存 %s: %v", imageInfoFile(), err)
+		return
+	}
+	imageInfoCacheMu.Lock()
+	defer imageInfoCacheMu.Unlock()
+	for filePath, entry := range entries {
+		if entry.Version == imageInfoVersion {
+			imageInfoCache[filePath] = entry
+		} else {
+			imageInfoDirty = true
+		}
+	}
+}
+
+// 标记缓存已修改，稍后统一写回，调用方需持有 imageInfoCacheMu
+func markImageInfoDirty() {
+	imageInfoDirty = true
+	if imageInfoSaveTimer == nil {
+		imageInfoSaveTimer = time.AfterFunc(imageInfoSaveDelay, saveImageInfo)
+	}
+}
+
+// 有新增或过期条目时写回缓存文件
+func saveImageInfo() {
+	imageInfoCacheMu.Lock()
+	defer imageInfoCacheMu.Unlock()
+	imageInfoSaveTimer = nil
+	if !imageInfoDirty {
+		return
+	}
+	content, err := json.Marshal(imageInfoCache)
+	if err != nil {
+		log.Printf("无法序列化图片信息缓存: %v", err)
+		return
+	}
+	if err := os.WriteFile(imageInfoFile(), content, 0644); err != nil {
+		log.Printf("无法写入图片信息缓存 %s: %v", imageInfoFile(), err)
+		return
+	}
+	imageInfoDirty = false
+}
+
+func (entry imageInfoEntry) matches(size int64, modTime time.Time) bool {
+	return entry.Version == imageInfoVersion && entry.Size == size && entry.ModTime.Equal(modTime)
+}
+
+// 读取图片尺寸，文件未变化时直接使用缓存；内容哈希在后台计算，计算完成前为空
+func imageInfo(filePath string, size int64, modTime time.Time) imageInfoEntry {
+	imageInfoCacheMu.Lock()
+	entry, ok := imageInfoCache[filePath]
+	if ok && entry.matches(size, modTime) {
+		queueImageHash(entry, filePath)
+		imageInfoCacheMu.Unlock()
+		return entry
+	}
+	imageInfoCacheMu.Unlock()
+
+	entry = imageInfoEntry{Version: imageInfoVersion, Size: size, ModTime: modTime}
+	file, err := openMedia(filePath)
+	if err != nil {
+		return entry
+	}
+	defer file.Close()
+	// 尺寸只需读取文件头
+	if cfg, _, err := image.DecodeConfig(file); err == nil {
+		entry.Width, entry.Height = cfg.Width, cfg.Height
+	}
+	// 按拍摄方向旋转90度的照片，显示时宽高互换
+	if data := cachedExif(filePath, modTime); data.Orientation >= 5 {
+		entry.Width, entry.Height = entry.Height, entry.Width
+	}
+
+	imageInfoCacheMu.Lock()
+	imageInfoCache[filePath] = entry
+	markImageInfoDirty()
+	queueImageHash(entry, filePath)
+	imageInfoCacheMu.Unlock()
+	return entry
+}
+
+// 将缺少哈希的图片加入后台队列，调用方需持有 imageInfoCacheMu。
+// 对象存储按请求与流量计费，不为计算哈希下载整个文件
+func queueImageHash(entry imageInfoEntry, filePath string) {
+	if entry.Hash != "" || !localStorage() || imageHashPending[filePath] {
+		return
+	}
+	select {
+	case imageHashQueue <- imageHashJob{path: filePath, size: entry.Size, modTime: entry.ModTime}:
+		imageHashPending[filePath] = true
+	default:
+	}
+}
+
+// 逐个计算队列中图片的哈希，文件在此期间被修改时丢弃结果
+func hashImages() {
+	for job := range imageHashQueue {
+		hash, err := hashMedia(job.path)
+		imageInfoCacheMu.Lock()
+		delete(imageHashPending, job.path)
+		if entry, ok := imageInfoCache[job.path]; ok && err == nil && entry.matches(job.size, job.modTime) {
+			entry.Hash = hash
+			imageInfoCache[job.path] = entry
+			markImageInfoDirty()
+		}
+		imageInfoCacheMu.Unlock()
+	}
+}
+
+// 计算文件内容的 SHA-256，取前 16 字节
+func hashMedia(filePath string) (string, error) {
+	file, err := openMedia(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16]), nil
+}
+
+// 补全图片的尺寸与哈希，dirPath 为图片所在目录；视频与音频文件较大，不读取内容
+func fillImageInfo(images []Image, dirPath string) {
+	for i := range images {
+		if images[i].Kind != mediaImage {
+			continue
+		}
+		entry := imageInfo(path.Join(dirPath, images[i].Name), images[i].Size, images[i].ModTime)
+		images[i].Width = entry.Width
+		images[i].Height = entry.Height
+		images[i].Hash = entry.Hash
+	}
+}
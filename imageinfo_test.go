@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+	"time"
+)
+
+// 尺寸立即返回，哈希在后台计算，缓存文件延迟写回
+func TestImageInfoBackgroundHash(t *testing.T) {
+	content := testPNG(t, 30, 20, 30, 20)
+	writeImageFile(t, "Info/a.png", content)
+	info, err := os.Stat("images/Info/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(imageInfoFile())
+
+	entry := imageInfo("Info/a.png", info.Size(), info.ModTime())
+	if entry.Width != 30 || entry.Height != 20 {
+		t.Errorf("尺寸为 %dx%d", entry.Width, entry.Height)
+	}
+	if _, err := os.Stat(imageInfoFile()); !os.IsNotExist(err) {
+		t.Error("缓存文件应延迟写回")
+	}
+
+	sum := sha256.Sum256(content)
+	want := hex.EncodeToString(sum[:16])
+	deadline := time.Now().Add(time.Second)
+	for entry.Hash == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		entry = imageInfo("Info/a.png", info.Size(), info.ModTime())
+	}
+	if entry.Hash != want {
+		t.Errorf("哈希为 %q，期望 %q", entry.Hash, want)
+	}
+
+	saveImageInfo()
+	if _, err := os.Stat(imageInfoFile()); err != nil {
+		t.Errorf("写回缓存文件失败: %v", err)
+	}
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"encoding/hex"
 	"log"
 	"os"
 
 	"gopkg.in/yaml.v2"
 )
 
-func init() {
-	configFileName := "conf/config.yaml"
+const configFileName = "conf/config.yaml"
+
+// 读取配置文件并初始化依赖配置的全局状态，测试中不调用，由测试自行设置配置
+func initConfig() {
 	configDir := "conf"
 	if _, err := os.Stat(configDir); os.IsNotExist(err) {
 		if err := os.Mkdir(configDir, 0755); err != nil {
@@ -31,13 +34,8 @@ func init() {
 		}
 		config = defauleConfig
 
-		content, err := yaml.Marshal(defauleConfig)
-		if err != nil {
-			log.Fatalf("无法序列化默认配置: %v", err)
-		}
-		if err := os.WriteFile(configFileName, content, 0644); err != nil {
-			log.Fatalf("无法创建默认配置文件 %s: %v", configFileName, err)
-		}
+		ensureSecrets()
+		saveConfig()
 
 		log.Println("配置文件不存在，已为您创建config.yaml，请根据需要修改配置。并重启服务。")
 	} else {
@@ -52,5 +50,47 @@ func init() {
 		if err := deyaml.Decode(&config); err != nil {
 			log.Fatalf("无法解析配置文件 %s: %v", configFileName, err)
 		}
+
+		// 未配置密钥时自动生成并写回配置文件
+		if ensureSecrets() {
+			saveConfig()
+			log.Println("已生成缺失的密钥并写入配置文件")
+		}
+	}
+
+	initSessionStore()
+	loadSessionRecords()
+	loadShareLinks()
+	loadAPITokens()
+	loadUsers()
+	initOAuthProviders()
+	initTrustedProxies()
+	checkAdminRequire2FA()
+	initMediaTypes()
+	initStorage()
+}
+
+// 补全缺失的签名密钥，更换 auth_secret 会使所有登录失效，返回是否有改动
+func ensureSecrets() bool {
+	changed := false
+	if config.AuthSecret == "" {
+		config.AuthSecret = hex.EncodeToString(generateRandomKey(32))
+		changed = true
+	}
+	if len(config.SessionKeys) == 0 {
+		config.SessionKeys = []SessionKey{newSessionKey()}
+		changed = true
+	}
+	return changed
+}
+
+// 将当前配置写回配置文件
+func saveConfig() {
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		log.Fatalf("无法序列化配置: %v", err)
+	}
+	if err := os.WriteFile(configFileName, content, 0600); err != nil {
+		log.Fatalf("无法写入配置文件 %s: %v", configFileName, err)
 	}
 }
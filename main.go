@@ -1,63 +1,203 @@
 package main
 
 import (
+	"errors"
+	"io/fs"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
+	"path"
 	"strings"
+	"time"
 )
 
-func scanCategories(imageDir string) []Category {
-	categories, err := os.ReadDir(imageDir)
+// 扫描图片存储中的所有分类，启动时无法读取直接退出
+func scanCategories() []Category {
+	categoryList, err := readCategories()
 	if err != nil {
-		log.Fatalf("无法读取目录 %s: %v", imageDir, err)
+		log.Fatalf("无法读取图片存储: %v", err)
+	}
+	return categoryList
+}
+
+func readCategories() ([]Category, error) {
+	categories, err := imageStore.ReadDir(".")
+	if err != nil {
+		return nil, err
 	}
 
 	var categoryList []Category
 	for _, category := range categories {
-		if category.IsDir() {
-			dirPath := filepath.Join(imageDir, category.Name())
-			entries, err := os.ReadDir(dirPath)
-			if err != nil {
-				log.Printf("无法读取目录 %s: %v", dirPath, err)
+		if hiddenName(category.Name()) || !category.IsDir() && !isArchiveName(category.Name()) {
+			continue
+		}
+		if item, ok := scanCategory(category.Name()); ok && !item.Hidden {
+			categoryList = append(categoryList, item)
+		}
+	}
+	sortCategories(categoryList)
+	return categoryList, nil
+}
+
+// 扫描单个分类，name 为相对图片目录的路径，可以是目录或归档文件，不存在或其中没有媒体文件时返回false
+func scanCategory(name string) (Category, bool) {
+	if isArchiveFile(name) {
+		return scanArchive(name)
+	}
+	meta := loadAlbumMeta(name)
+	cover, ok := meta.coverImage(name)
+	if !ok {
+		cover, ok = findCover(name)
+	}
+	if !ok {
+		return Category{}, false
+	}
+	count, modTime := albumStats(name)
+	return Category{
+		Name:        path.Base(name),
+		Path:        name,
+		EncodedName: encodeCategoryPath(name),
+		CoverImage:  cover,
+		Title:       meta.title(name),
+		Description: meta.Description,
+		Tags:        meta.Tags,
+		SortKey:     meta.Sort,
+		Hidden:      meta.Hidden,
+		ImageCount:  count,
+		modTime:     modTime,
+	}, true
+}
+
+// 查找封面，优先使用图片；分类及子目录中只有视频或音频时使用第一个媒体文件，返回相对该目录的路径
+func findCover(dirPath string) (string, bool) {
+	if cover, ok := findCoverOf(dirPath, func(kind string) bool { return kind == mediaImage }); ok {
+		return cover, true
+	}
+	return findCoverOf(dirPath, func(kind string) bool { return kind != "" })
+}
+
+// 优先使用目录中第一个符合条件的文件，没有时依次从未隐藏的子目录与归档中查找
+func findCoverOf(dirPath string, accept func(kind string) bool) (string, bool) {
+	entries, err := imageStore.ReadDir(dirPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("无法读取目录 %s: %v", dirPath, err)
+		}
+		return "", false
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if accept(mediaKind(entry.Name())) {
+			return entry.Name(), true
+		}
+	}
+	for _, entry := range entries {
+		if hiddenName(entry.Name()) {
+			continue
+		}
+		childPath := path.Join(dirPath, entry.Name())
+		if !entry.IsDir() {
+			if !entry.Type().IsRegular() || !isArchiveName(entry.Name()) {
 				continue
 			}
-
-			var coverImage string
-			for _, entry := range entries {
-				if entry.IsDir() {
-					continue
-				}
-				ext := strings.ToLower(filepath.Ext(entry.Name()))
-				if imageExtensions[ext] {
-					coverImage = entry.Name()
-					break
+			if index, err := openArchive(childPath); err == nil {
+				if cover, ok := index.cover(accept); ok {
+					return entry.Name() + "/" + cover, true
 				}
 			}
-			if coverImage != "" {
-				categoryList = append(categoryList, Category{
-					Name:        category.Name(),
-					EncodedName: url.PathEscape(category.Name()),
-					CoverImage:  coverImage,
-				})
+			continue
+		}
+		meta := loadAlbumMeta(childPath)
+		if meta.Hidden {
+			continue
+		}
+		cover, ok := meta.coverImage(childPath)
+		if !ok {
+			cover, ok = findCoverOf(childPath, accept)
+		}
+		if ok {
+			return entry.Name() + "/" + cover, true
+		}
+	}
+	return "", false
+}
+
+// 统计目录及未隐藏的子目录、归档中的媒体文件数量与最近修改时间
+func albumStats(dirPath string) (int, time.Time) {
+	count := 0
+	var latest time.Time
+	fs.WalkDir(imageStore, dirPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != dirPath && (hiddenName(d.Name()) || loadAlbumMeta(p).Hidden) {
+				return fs.SkipDir
 			}
+			return nil
 		}
+		if d.Type().IsRegular() && isArchiveName(d.Name()) {
+			if index, err := openArchive(p); err == nil && len(index.entries) > 0 {
+				count += len(index.entries)
+				if index.modTime.After(latest) {
+					latest = index.modTime
+				}
+			}
+			return nil
+		}
+		if mediaKind(d.Name()) == "" {
+			return nil
+		}
+		count++
+		if info, err := d.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return count, latest
+}
+
+// 以点开头的文件和目录不作为分类展示
+func hiddenName(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// 逐段转义分类路径，保留层级分隔符
+func encodeCategoryPath(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
 	}
-	return categoryList
+	return strings.Join(segments, "/")
 }
 
 func main() {
-
-	categoryCache = scanCategories(config.ImageDir)
+	initConfig()
+	setCategories(scanCategories())
+	startCategoryWatcher()
+	initThumbnails()
+	initImageInfo()
+	initDownloads()
+	initSearch()
 
 	// 路由设置
 	http.HandleFunc("/login", loginHandler)
+	http.HandleFunc("/login/2fa", loginTOTPHandler)
+	http.HandleFunc("/logout", logoutHandler)
+	http.Handle("/admin/", AuthMiddleware(adminMiddleware(http.HandlerFunc(adminHandler))))
+	http.Handle("/admin/sessions/revoke", AuthMiddleware(adminMiddleware(http.HandlerFunc(adminRevokeSessionHandler))))
+	http.Handle("/admin/shares/create", AuthMiddleware(adminMiddleware(http.HandlerFunc(adminCreateShareHandler))))
+	http.Handle("/admin/shares/revoke", AuthMiddleware(adminMiddleware(http.HandlerFunc(adminRevokeShareHandler))))
+	http.Handle("/admin/tokens/revoke", AuthMiddleware(adminMiddleware(http.HandlerFunc(adminRevokeTokenHandler))))
+	http.Handle("/account/tokens", AuthMiddleware(http.HandlerFunc(accountTokensHandler)))
+	http.Handle("/account/2fa", AuthMiddleware(http.HandlerFunc(accountTOTPHandler)))
+	http.HandleFunc("/s/", shareHandler)
 
-	if config.LinuxdoEnable != "false" {
-		http.HandleFunc("/oauth2/linxdo", initiateAuthHandler)
-		http.HandleFunc("/oauth2/callback", callbackHandler)
+	if len(oauthProviders) > 0 {
+		http.HandleFunc("/oauth2/", oauthHandler)
 	}
 
 	if config.Dynamic == "true" {
@@ -67,10 +207,14 @@ func main() {
 
 	http.Handle("/", AuthMiddleware(http.HandlerFunc(indexHandler)))
 	http.Handle("/category/", AuthMiddleware(http.HandlerFunc(categoryHandler)))
-	http.Handle("/images/", AuthMiddleware(http.StripPrefix("/images/", http.FileServer(http.Dir(config.ImageDir)))))
+	http.Handle("/api/image/", AuthMiddleware(http.HandlerFunc(imageJson)))
+	http.Handle("/api/search", AuthMiddleware(http.HandlerFunc(searchJson)))
+	http.Handle("/thumbs/", AuthMiddleware(http.HandlerFunc(thumbHandler)))
+	http.Handle("/download/", AuthMiddleware(http.HandlerFunc(downloadHandler)))
+	http.Handle("/images/", AuthMiddleware(http.StripPrefix("/images/", imageACLMiddleware(archiveMiddleware(imageFileServer())))))
 
 	log.Println("服务器启动在 :", config.Port)
-	if err := http.ListenAndServe(":"+config.Port, loggingMiddleware(http.DefaultServeMux)); err != nil {
+	if err := http.ListenAndServe(":"+config.Port, loggingMiddleware(csrfMiddleware(http.DefaultServeMux))); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试在临时目录中运行，conf 与 images 都在其中，不会读写仓库中的配置
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "plist-test-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	os.Mkdir("conf", 0755)
+	os.Mkdir("images", 0755)
+
+	config = Config{
+		ImageDir:      "images",
+		WebAdderss:    "http://plist.test",
+		LinuxdoEnable: "false",
+		CookieSecure:  "false",
+		CacheDir:      filepath.Join(dir, "cache"),
+	}
+	ensureSecrets()
+	initSessionStore()
+	initMediaTypes()
+	initStorage()
+	initThumbnails()
+	initImageInfo()
+	initDownloads()
+
+	code := m.Run()
+	os.Chdir(wd)
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// 将响应中设置的 cookie 带到下一个请求
+func addCookies(r *http.Request, resp *http.Response) {
+	for _, cookie := range resp.Cookies() {
+		r.AddCookie(cookie)
+	}
+}
+
+// 在图片目录中写入测试文件
+func writeImageFile(t *testing.T, name string, content []byte) {
+	t.Helper()
+	p := filepath.Join(config.ImageDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
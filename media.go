@@ -0,0 +1,106 @@
+package main
+
+import (
+	"log"
+	"mime"
+	"path"
+	"slices"
+	"strings"
+)
+
+// 媒体类型
+const (
+	mediaImage = "image"
+	mediaVideo = "video"
+	mediaAudio = "audio"
+)
+
+// 未配置 media_kinds 时启用的媒体类型
+var defaultMediaKinds = []string{mediaImage, mediaVideo}
+
+// 扩展名对应的媒体类型与 MIME 类型
+type mediaType struct {
+	Kind string
+	Mime string
+}
+
+var builtinMediaTypes = map[string]mediaType{
+	".jpg":  {mediaImage, "image/jpeg"},
+	".jpeg": {mediaImage, "image/jpeg"},
+	".png":  {mediaImage, "image/png"},
+	".gif":  {mediaImage, "image/gif"},
+	".webp": {mediaImage, "image/webp"},
+	".svg":  {mediaImage, "image/svg+xml"},
+	".ico":  {mediaImage, "image/x-icon"},
+	".mp4":  {mediaVideo, "video/mp4"},
+	".m4v":  {mediaVideo, "video/mp4"},
+	".webm": {mediaVideo, "video/webm"},
+	// 手机拍摄的 mov 多为 H.264 编码，按 mp4 提供时主流浏览器都能直接播放
+	".mov":  {mediaVideo, "video/mp4"},
+	".mp3":  {mediaAudio, "audio/mpeg"},
+	".m4a":  {mediaAudio, "audio/mp4"},
+	".aac":  {mediaAudio, "audio/aac"},
+	".ogg":  {mediaAudio, "audio/ogg"},
+	".opus": {mediaAudio, "audio/ogg"},
+	".flac": {mediaAudio, "audio/flac"},
+	".wav":  {mediaAudio, "audio/wav"},
+}
+
+// 当前启用的扩展名，初始化后只读
+var mediaTypes = map[string]mediaType{}
+
+// 按配置生成启用的扩展名列表，并注册 MIME 类型，使文件服务返回正确的 Content-Type
+func initMediaTypes() {
+	kinds := config.MediaKinds
+	if len(kinds) == 0 {
+		kinds = defaultMediaKinds
+	}
+	for _, kind := range kinds {
+		if !validMediaKind(kind) {
+			log.Fatalf("未知的媒体类型 %s，可选 image、video、audio", kind)
+		}
+	}
+	for ext, t := range builtinMediaTypes {
+		if slices.Contains(kinds, t.Kind) {
+			mediaTypes[ext] = t
+		}
+	}
+	for _, extra := range config.MediaTypes {
+		ext := strings.ToLower(extra.Ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if ext == "." || !validMediaKind(extra.Kind) {
+			log.Fatalf("无效的媒体类型配置: 扩展名 %q，类型 %q", extra.Ext, extra.Kind)
+		}
+		mimeType := extra.Mime
+		if mimeType == "" {
+			mimeType = mime.TypeByExtension(ext)
+		}
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		mediaTypes[ext] = mediaType{Kind: extra.Kind, Mime: mimeType}
+	}
+	for ext, t := range mediaTypes {
+		if err := mime.AddExtensionType(ext, t.Mime); err != nil {
+			log.Fatalf("无法注册 MIME 类型 %s %s: %v", ext, t.Mime, err)
+		}
+	}
+}
+
+func validMediaKind(kind string) bool {
+	return kind == mediaImage || kind == mediaVideo || kind == mediaAudio
+}
+
+// 根据文件名查找媒体类型，不支持或未启用的格式返回false
+func lookupMedia(name string) (mediaType, bool) {
+	t, ok := mediaTypes[strings.ToLower(path.Ext(name))]
+	return t, ok
+}
+
+// 文件的媒体类型，不支持或未启用的格式返回空字符串
+func mediaKind(name string) string {
+	t, _ := lookupMedia(name)
+	return t.Kind
+}
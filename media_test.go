@@ -0,0 +1,70 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 生成内容各不相同的测试数据，便于核对返回的字节范围
+func testContent(n int) []byte {
+	content := make([]byte, n)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	return content
+}
+
+// /images/ 路由对普通文件与归档内的文件都支持 Range 请求，视频可以拖动进度条而无需完整下载
+func TestImagesRange(t *testing.T) {
+	content := testContent(1000)
+	writeImageFile(t, "RangeTest/a.jpg", content)
+	writeTestZip(t, "RangeStored.zip", map[string][]byte{"b.jpg": content}, zip.Store)
+	writeTestZip(t, "RangeDeflated.zip", map[string][]byte{"c.jpg": content}, zip.Deflate)
+	handler := http.StripPrefix("/images/", imageACLMiddleware(archiveMiddleware(imageFileServer())))
+
+	for _, target := range []string{
+		"/images/RangeTest/a.jpg",
+		"/images/RangeStored.zip/b.jpg",
+		"/images/RangeDeflated.zip/c.jpg",
+	} {
+		for _, tt := range []struct {
+			header       string
+			contentRange string
+			start, end   int
+		}{
+			{"bytes=100-199", "bytes 100-199/1000", 100, 200},
+			{"bytes=900-", "bytes 900-999/1000", 900, 1000},
+			{"bytes=-10", "bytes 990-999/1000", 990, 1000},
+		} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set("Range", tt.header)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			resp := rec.Result()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusPartialContent {
+				t.Errorf("%s %s: 返回 %d，期望 206", target, tt.header, resp.StatusCode)
+				continue
+			}
+			if got := resp.Header.Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("%s %s: Content-Range = %q，期望 %q", target, tt.header, got, tt.contentRange)
+			}
+			if !bytes.Equal(body, content[tt.start:tt.end]) {
+				t.Errorf("%s %s: 返回内容与请求范围不符", target, tt.header)
+			}
+		}
+	}
+
+	// 超出文件大小的范围返回 416
+	req := httptest.NewRequest(http.MethodGet, "/images/RangeTest/a.jpg", nil)
+	req.Header.Set("Range", "bytes=5000-")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("超出范围返回 %d，期望 416", rec.Code)
+	}
+}
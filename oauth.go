@@ -0,0 +1,443 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// OAuth2 / OpenID Connect 登录提供方
+type OAuthProvider struct {
+	Name             string   `yaml:"name"`         // 路由标识，对应 /oauth2/{name}
+	DisplayName      string   `yaml:"display_name"` // 登录按钮上显示的名称
+	Preset           string   `yaml:"preset"`       // 预设模板，目前支持 linuxdo
+	Issuer           string   `yaml:"issuer"`       // OIDC 发现地址，自动读取 /.well-known/openid-configuration
+	AuthorizationURL string   `yaml:"authorization_url"`
+	TokenURL         string   `yaml:"token_url"`
+	UserInfoURL      string   `yaml:"userinfo_url"`
+	ClientID         string   `yaml:"client_id"`
+	ClientSecret     string   `yaml:"client_secret"`
+	Scopes           []string `yaml:"scopes"`
+	TokenAuthMethod  string   `yaml:"token_auth_method"` // basic（默认）或 post
+	UsernameClaim    string   `yaml:"username_claim"`    // 支持 a.b 形式的嵌套字段
+	AvatarClaim      string   `yaml:"avatar_claim"`
+
+	// 准入限制，trust_level、active、silenced 取自用户信息中的同名字段（Linux do 提供）
+	MinTrustLevel int      `yaml:"min_trust_level"`
+	RequireActive string   `yaml:"require_active"` // 为 true 时拒绝未激活账号
+	DenySilenced  string   `yaml:"deny_silenced"`  // 为 true 时拒绝被禁言账号
+	AllowUsers    []string `yaml:"allow_users"`    // 非空时仅允许名单内用户
+	DenyUsers     []string `yaml:"deny_users"`
+
+	// 由旧版 linuxdo_* 配置生成，沿用原有的回调地址
+	legacy bool
+}
+
+type oauthEndpoints struct {
+	Authorization string `json:"authorization_endpoint"`
+	Token         string `json:"token_endpoint"`
+	UserInfo      string `json:"userinfo_endpoint"`
+}
+
+var (
+	oauthProviders []OAuthProvider
+
+	discoveryMu    sync.Mutex
+	discoveryCache = make(map[string]oauthEndpoints)
+)
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// 保留的提供方名称：callback 为旧版回调路由，其余为 UserInfo.Provider 中内置登录方式的取值，
+// 第三方登录若使用这些名称会被当作本地账号、代理用户或分享访客
+var reservedProviderNames = map[string]bool{
+	"callback": true,
+	"oauth":    true,
+	"password": true,
+	"user":     true,
+	"share":    true,
+	"proxy":    true,
+}
+
+// 整理登录提供方配置，旧版 linuxdo_* 配置会转换为 linuxdo 预设
+func initOAuthProviders() {
+	providers := append([]OAuthProvider(nil), config.OAuthProviders...)
+	if config.LinuxdoEnable != "false" && findConfiguredProvider(providers, "linuxdo") < 0 {
+		providers = append(providers, OAuthProvider{
+			Name:          "linuxdo",
+			Preset:        "linuxdo",
+			ClientID:      config.LinuxdoClientId,
+			ClientSecret:  config.LinuxdoClientSecret,
+			MinTrustLevel: config.LinuxdoMinTrustLevel,
+			AllowUsers:    config.LinuxdoAllowUsers,
+			DenyUsers:     config.LinuxdoDenyUsers,
+			legacy:        true,
+		})
+	}
+
+	for i := range providers {
+		p := &providers[i]
+		if p.Preset != "" {
+			preset, ok := oauthPresets[p.Preset]
+			if !ok {
+				log.Fatalf("登录提供方 %s 的预设 %s 不存在", p.Name, p.Preset)
+			}
+			p.applyDefaults(preset)
+		}
+		if p.Issuer != "" {
+			p.applyDefaults(OAuthProvider{
+				Scopes:        []string{"openid", "profile"},
+				UsernameClaim: "preferred_username",
+				AvatarClaim:   "picture",
+			})
+		}
+		p.applyDefaults(OAuthProvider{DisplayName: p.Name, UsernameClaim: "username", TokenAuthMethod: "basic"})
+
+		if !providerNamePattern.MatchString(p.Name) {
+			log.Fatalf("登录提供方名称 %q 无效，只能包含小写字母、数字、- 和 _", p.Name)
+		}
+		if reservedProviderNames[p.Name] {
+			log.Fatalf("登录提供方名称 %s 为保留名称", p.Name)
+		}
+		if findConfiguredProvider(providers[:i], p.Name) >= 0 {
+			log.Fatalf("登录提供方名称 %s 重复", p.Name)
+		}
+		if p.Issuer == "" && (p.AuthorizationURL == "" || p.TokenURL == "" || p.UserInfoURL == "") {
+			log.Fatalf("登录提供方 %s 需要配置 issuer 或完整的 authorization_url、token_url、userinfo_url", p.Name)
+		}
+	}
+	oauthProviders = providers
+}
+
+func findConfiguredProvider(providers []OAuthProvider, name string) int {
+	for i, p := range providers {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func findProvider(name string) (*OAuthProvider, bool) {
+	i := findConfiguredProvider(oauthProviders, name)
+	if i < 0 {
+		return nil, false
+	}
+	return &oauthProviders[i], true
+}
+
+// 用默认值补全未配置的字段
+func (p *OAuthProvider) applyDefaults(d OAuthProvider) {
+	setDefault := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	setDefault(&p.DisplayName, d.DisplayName)
+	setDefault(&p.Issuer, d.Issuer)
+	setDefault(&p.AuthorizationURL, d.AuthorizationURL)
+	setDefault(&p.TokenURL, d.TokenURL)
+	setDefault(&p.UserInfoURL, d.UserInfoURL)
+	setDefault(&p.TokenAuthMethod, d.TokenAuthMethod)
+	setDefault(&p.UsernameClaim, d.UsernameClaim)
+	setDefault(&p.AvatarClaim, d.AvatarClaim)
+	if len(p.Scopes) == 0 {
+		p.Scopes = d.Scopes
+	}
+}
+
+func (p *OAuthProvider) redirectURL() string {
+	if p.legacy {
+		return config.WebAdderss + "/oauth2/callback"
+	}
+	return config.WebAdderss + "/oauth2/" + p.Name + "/callback"
+}
+
+// 获取授权相关地址，配置了 issuer 时通过发现文档补全并缓存
+func (p *OAuthProvider) endpoints() (oauthEndpoints, error) {
+	ep := oauthEndpoints{
+		Authorization: p.AuthorizationURL,
+		Token:         p.TokenURL,
+		UserInfo:      p.UserInfoURL,
+	}
+	if p.Issuer == "" || (ep.Authorization != "" && ep.Token != "" && ep.UserInfo != "") {
+		return ep, nil
+	}
+
+	discoveryMu.Lock()
+	defer discoveryMu.Unlock()
+	discovered, ok := discoveryCache[p.Issuer]
+	if !ok {
+		resp, err := resty.New().R().
+			SetHeader("Accept", "application/json").
+			Get(strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration")
+		if err != nil {
+			return ep, err
+		}
+		if resp.StatusCode() != http.StatusOK {
+			return ep, fmt.Errorf("发现文档返回状态码 %d", resp.StatusCode())
+		}
+		if err := json.Unmarshal(resp.Body(), &discovered); err != nil {
+			return ep, err
+		}
+		discoveryCache[p.Issuer] = discovered
+	}
+	if ep.Authorization == "" {
+		ep.Authorization = discovered.Authorization
+	}
+	if ep.Token == "" {
+		ep.Token = discovered.Token
+	}
+	if ep.UserInfo == "" {
+		ep.UserInfo = discovered.UserInfo
+	}
+	if ep.Authorization == "" || ep.Token == "" || ep.UserInfo == "" {
+		return ep, fmt.Errorf("发现文档缺少必要的地址")
+	}
+	return ep, nil
+}
+
+// 按映射规则从用户信息中提取用户
+func (p *OAuthProvider) mapUser(claims map[string]interface{}) User {
+	user := User{
+		Username:  claimString(claims, p.UsernameClaim),
+		AvatarURL: claimString(claims, p.AvatarClaim),
+		Active:    true,
+		Claims:    claims,
+	}
+	if v, ok := claims["trust_level"].(float64); ok {
+		user.TrustLevel = int(v)
+	}
+	if v, ok := claims["active"].(bool); ok {
+		user.Active = v
+	}
+	if v, ok := claims["silenced"].(bool); ok {
+		user.Silenced = v
+	}
+	return user
+}
+
+// 检查用户是否满足准入限制，返回拒绝原因
+func (p *OAuthProvider) denyReason(user User) string {
+	matchUser := func(list []string) bool {
+		for _, name := range list {
+			if strings.EqualFold(name, user.Username) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case matchUser(p.DenyUsers):
+		return "该账号已被禁止访问"
+	case len(p.AllowUsers) > 0 && !matchUser(p.AllowUsers):
+		return "该账号不在允许访问的名单中"
+	case p.RequireActive == "true" && !user.Active:
+		return "该账号尚未激活"
+	case p.DenySilenced == "true" && user.Silenced:
+		return "该账号已被禁言"
+	case user.TrustLevel < p.MinTrustLevel:
+		return fmt.Sprintf("需要信任等级 %d 及以上，当前等级为 %d", p.MinTrustLevel, user.TrustLevel)
+	}
+	return ""
+}
+
+// 读取字段值，支持 a.b 形式的嵌套字段
+func claimString(claims map[string]interface{}, path string) string {
+	if path == "" {
+		return ""
+	}
+	var value interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[key]
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64, bool:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// OAuth2 路由分发：/oauth2/{name} 发起授权，/oauth2/{name}/callback 处理回调
+func oauthHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(r.URL.Path[len("/oauth2/"):], "/")
+	name, action, _ := strings.Cut(rest, "/")
+
+	// 兼容旧版 Linux do 登录地址
+	switch {
+	case name == "linxdo" && action == "":
+		name = "linuxdo"
+	case name == "callback" && action == "":
+		name, action = "linuxdo", "callback"
+	}
+
+	p, ok := findProvider(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch action {
+	case "":
+		initiateAuthHandler(w, r, p)
+	case "callback":
+		callbackHandler(w, r, p)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// 发起授权
+func initiateAuthHandler(w http.ResponseWriter, r *http.Request, p *OAuthProvider) {
+	ep, err := p.endpoints()
+	if err != nil {
+		log.Printf("无法获取登录提供方 %s 的授权地址: %v", p.Name, err)
+		http.Error(w, "登录服务暂不可用", http.StatusBadGateway)
+		return
+	}
+
+	session, _ := store.Get(r, "session-name")
+
+	// 生成随机的 state
+	state := hex.EncodeToString(generateRandomKey(16))
+	session.Values["oauth_state"] = state
+	session.Values["oauth_provider"] = p.Name
+	session.Save(r, w)
+
+	// 构造授权 URL
+	query := url.Values{}
+	query.Set("client_id", p.ClientID)
+	query.Set("response_type", "code")
+	query.Set("redirect_uri", p.redirectURL())
+	query.Set("state", state)
+	if len(p.Scopes) > 0 {
+		query.Set("scope", strings.Join(p.Scopes, " "))
+	}
+	sep := "?"
+	if strings.Contains(ep.Authorization, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, ep.Authorization+sep+query.Encode(), http.StatusFound)
+}
+
+// 处理回调
+func callbackHandler(w http.ResponseWriter, r *http.Request, p *OAuthProvider) {
+	session, _ := store.Get(r, "session-name")
+
+	// 获取查询参数
+	code := r.URL.Query().Get("code")
+	state := r.URL.Query().Get("state")
+
+	// 验证 state
+	storedState, _ := session.Values["oauth_state"].(string)
+	storedProvider, _ := session.Values["oauth_provider"].(string)
+	if storedState == "" || state != storedState || storedProvider != p.Name {
+		http.Error(w, "State value does not match", http.StatusUnauthorized)
+		return
+	}
+	delete(session.Values, "oauth_state")
+	delete(session.Values, "oauth_provider")
+
+	ep, err := p.endpoints()
+	if err != nil {
+		log.Printf("无法获取登录提供方 %s 的授权地址: %v", p.Name, err)
+		http.Error(w, "登录服务暂不可用", http.StatusBadGateway)
+		return
+	}
+
+	// 创建 HTTP 客户端
+	client := resty.New()
+
+	// 请求 access token
+	form := map[string]string{
+		"grant_type":   "authorization_code",
+		"code":         code,
+		"redirect_uri": p.redirectURL(),
+	}
+	req := client.R().SetHeader("Accept", "application/json")
+	if p.TokenAuthMethod == "post" {
+		form["client_id"] = p.ClientID
+		form["client_secret"] = p.ClientSecret
+	} else {
+		req.SetBasicAuth(p.ClientID, p.ClientSecret)
+	}
+	resp, err := req.SetFormData(form).Post(ep.Token)
+
+	if err != nil || resp.StatusCode() != http.StatusOK {
+		http.Error(w, "Failed to fetch access token", http.StatusInternalServerError)
+		return
+	}
+
+	// 解析 token 响应
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(resp.Body(), &tokenResp); err != nil || tokenResp.AccessToken == "" {
+		http.Error(w, "Failed to parse token response", http.StatusInternalServerError)
+		return
+	}
+
+	// 获取用户信息
+	userResp, err := client.R().
+		SetHeader("Accept", "application/json").
+		SetHeader("Authorization", fmt.Sprintf("Bearer %s", tokenResp.AccessToken)).
+		Get(ep.UserInfo)
+
+	if err != nil || userResp.StatusCode() != http.StatusOK {
+		http.Error(w, "Failed to fetch user info", http.StatusInternalServerError)
+		return
+	}
+
+	// 解析用户信息
+	var claims map[string]interface{}
+	if err := json.Unmarshal(userResp.Body(), &claims); err != nil {
+		http.Error(w, "Failed to parse user response", http.StatusInternalServerError)
+		return
+	}
+	user := p.mapUser(claims)
+	if user.Username == "" {
+		log.Printf("登录提供方 %s 返回的用户信息缺少 %s 字段", p.Name, p.UsernameClaim)
+		http.Error(w, "Failed to parse user response", http.StatusInternalServerError)
+		return
+	}
+
+	if reason := p.denyReason(user); reason != "" {
+		log.Printf("拒绝 %s 用户 %s 登录: %s", p.Name, user.Username, reason)
+		session.Save(r, w)
+		w.WriteHeader(http.StatusForbidden)
+		tmpl := template.Must(template.New("denied").Parse(deniedTemplate))
+		tmpl.Execute(w, struct {
+			Config   Config
+			Provider string
+			Username string
+			Reason   string
+		}{
+			Config:   config,
+			Provider: p.DisplayName,
+			Username: user.Username,
+			Reason:   reason,
+		})
+		return
+	}
+
+	session.Values["username"] = user.Username
+	session.Values["avatar"] = user.AvatarURL
+	session.Save(r, w)
+
+	setAuthCookie(w, r, "oauth:"+p.Name+":"+user.Username)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// 模拟的 OIDC 提供方，code 对应返回的用户信息
+type mockIdP struct {
+	*httptest.Server
+	t          *testing.T
+	authMethod string // 期望的客户端认证方式
+	users      map[string]map[string]interface{}
+}
+
+func newMockIdP(t *testing.T, authMethod string) *mockIdP {
+	idp := &mockIdP{t: t, authMethod: authMethod, users: make(map[string]map[string]interface{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"userinfo_endpoint":      idp.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		code, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer at-")
+		claims, found := idp.users[code]
+		if !ok || !found {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(claims)
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if got := r.PostForm.Get("redirect_uri"); got != config.WebAdderss+"/oauth2/corp/callback" {
+		idp.t.Errorf("redirect_uri = %q", got)
+	}
+	var id, secret string
+	switch idp.authMethod {
+	case "basic":
+		var ok bool
+		id, secret, ok = r.BasicAuth()
+		if !ok || r.PostForm.Has("client_secret") {
+			idp.t.Errorf("客户端认证应使用 Basic 请求头")
+		}
+	case "post":
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		if r.Header.Get("Authorization") != "" {
+			idp.t.Errorf("客户端认证应使用表单字段")
+		}
+	}
+	if id != "plist" || secret != "s3cret" {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "at-" + r.PostForm.Get("code"), "token_type": "Bearer"})
+}
+
+// 完成一次登录流程，返回回调的响应
+func oauthLogin(t *testing.T, name, code string) *http.Response {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	oauthHandler(rec, httptest.NewRequest(http.MethodGet, "/oauth2/"+name, nil))
+	start := rec.Result()
+	if start.StatusCode != http.StatusFound {
+		t.Fatalf("发起授权返回 %d", start.StatusCode)
+	}
+	location, err := url.Parse(start.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := location.Query()
+	if location.Path != "/authorize" || query.Get("client_id") != "plist" || query.Get("scope") != "openid profile" ||
+		query.Get("redirect_uri") != config.WebAdderss+"/oauth2/"+name+"/callback" || query.Get("state") == "" {
+		t.Fatalf("授权地址不正确: %s", location)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/"+name+"/callback?"+url.Values{
+		"code":  {code},
+		"state": {query.Get("state")},
+	}.Encode(), nil)
+	addCookies(req, start)
+	rec = httptest.NewRecorder()
+	oauthHandler(rec, req)
+	return rec.Result()
+}
+
+func setupOAuthProvider(t *testing.T, p OAuthProvider) {
+	t.Helper()
+	saved := config.OAuthProviders
+	t.Cleanup(func() {
+		config.OAuthProviders = saved
+		initOAuthProviders()
+	})
+	config.OAuthProviders = []OAuthProvider{p}
+	initOAuthProviders()
+}
+
+func TestOAuthLogin(t *testing.T) {
+	for _, method := range []string{"basic", "post"} {
+		t.Run(method, func(t *testing.T) {
+			idp := newMockIdP(t, method)
+			idp.users["alice"] = map[string]interface{}{
+				"preferred_username": "alice",
+				"picture":            "https://idp.test/alice.png",
+			}
+			setupOAuthProvider(t, OAuthProvider{
+				Name:            "corp",
+				Issuer:          idp.URL,
+				ClientID:        "plist",
+				ClientSecret:    "s3cret",
+				TokenAuthMethod: method,
+			})
+
+			resp := oauthLogin(t, "corp", "alice")
+			if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/" {
+				t.Fatalf("回调返回 %d %s", resp.StatusCode, resp.Header.Get("Location"))
+			}
+			var subject string
+			for _, cookie := range resp.Cookies() {
+				if cookie.Name == "auth" {
+					claims, err := parseAuthToken(cookie.Value)
+					if err != nil {
+						t.Fatal(err)
+					}
+					subject = claims.Subject
+				}
+			}
+			if subject != "oauth:corp:alice" {
+				t.Errorf("登录主体 = %q，期望 oauth:corp:alice", subject)
+			}
+		})
+	}
+}
+
+func TestOAuthLoginDenied(t *testing.T) {
+	idp := newMockIdP(t, "basic")
+	idp.users["bob"] = map[string]interface{}{"preferred_username": "bob", "trust_level": float64(1)}
+	setupOAuthProvider(t, OAuthProvider{
+		Name:          "corp",
+		Issuer:        idp.URL,
+		ClientID:      "plist",
+		ClientSecret:  "s3cret",
+		MinTrustLevel: 2,
+	})
+
+	resp := oauthLogin(t, "corp", "bob")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("回调返回 %d，期望 403", resp.StatusCode)
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "auth" {
+			t.Error("被拒绝的用户不应获得认证cookie")
+		}
+	}
+}
+
+func TestOAuthStateMismatch(t *testing.T) {
+	idp := newMockIdP(t, "basic")
+	setupOAuthProvider(t, OAuthProvider{Name: "corp", Issuer: idp.URL, ClientID: "plist", ClientSecret: "s3cret"})
+
+	rec := httptest.NewRecorder()
+	oauthHandler(rec, httptest.NewRequest(http.MethodGet, "/oauth2/corp/callback?code=alice&state=forged", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("伪造的 state 返回 %d，期望 401", rec.Code)
+	}
+}
+
+func TestOAuthMapUser(t *testing.T) {
+	p := OAuthProvider{UsernameClaim: "profile.login", AvatarClaim: "profile.avatar"}
+	user := p.mapUser(map[string]interface{}{
+		"profile":     map[string]interface{}{"login": "carol", "avatar": "https://idp.test/c.png"},
+		"trust_level": float64(3),
+		"active":      false,
+		"silenced":    true,
+	})
+	if user.Username != "carol" || user.AvatarURL != "https://idp.test/c.png" {
+		t.Errorf("嵌套字段映射错误: %+v", user)
+	}
+	if user.TrustLevel != 3 || user.Active || !user.Silenced {
+		t.Errorf("准入字段映射错误: %+v", user)
+	}
+	if got := p.mapUser(map[string]interface{}{}); !got.Active || got.Username != "" {
+		t.Errorf("缺少字段时应视为已激活且用户名为空: %+v", got)
+	}
+}
+
+func TestOAuthDenyReason(t *testing.T) {
+	p := OAuthProvider{
+		MinTrustLevel: 2,
+		RequireActive: "true",
+		DenySilenced:  "true",
+		AllowUsers:    []string{"Alice", "bob", "carol", "dave"},
+		DenyUsers:     []string{"bob"},
+	}
+	tests := []struct {
+		name   string
+		user   User
+		denied bool
+	}{
+		{"允许", User{Username: "alice", TrustLevel: 2, Active: true}, false},
+		{"黑名单", User{Username: "BOB", TrustLevel: 4, Active: true}, true},
+		{"不在白名单", User{Username: "eve", TrustLevel: 4, Active: true}, true},
+		{"未激活", User{Username: "carol", TrustLevel: 4}, true},
+		{"被禁言", User{Username: "carol", TrustLevel: 4, Active: true, Silenced: true}, true},
+		{"信任等级不足", User{Username: "dave", TrustLevel: 1, Active: true}, true},
+	}
+	for _, tt := range tests {
+		if reason := p.denyReason(tt.user); (reason != "") != tt.denied {
+			t.Errorf("%s: denyReason = %q", tt.name, reason)
+		}
+	}
+	if reason := (&OAuthProvider{}).denyReason(User{Username: "anyone"}); reason != "" {
+		t.Errorf("未配置限制时不应拒绝: %q", reason)
+	}
+}
+
+// 内置登录方式写入 UserInfo.Provider 的取值不能作为第三方提供方名称，
+// 否则第三方登录的同名用户会被当作本地账号
+func TestOAuthReservedProviderNames(t *testing.T) {
+	for _, name := range []string{"callback", "oauth", "password", "user", "share", "proxy"} {
+		if !reservedProviderNames[name] {
+			t.Errorf("%s 应为保留名称", name)
+		}
+	}
+	if reservedProviderNames["corp"] {
+		t.Error("corp 不应为保留名称")
+	}
+}
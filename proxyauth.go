@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+var trustedProxies []netip.Prefix
+
+// 解析受信任代理地址，支持 CIDR 与单个IP
+func initTrustedProxies() {
+	trustedProxies = nil
+	for _, item := range config.TrustedProxies {
+		item = strings.TrimSpace(item)
+		if prefix, err := netip.ParsePrefix(item); err == nil {
+			trustedProxies = append(trustedProxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			log.Fatalf("trusted_proxies 中的地址 %s 无效", item)
+		}
+		trustedProxies = append(trustedProxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	if proxyAuthEnabled() && len(trustedProxies) == 0 {
+		log.Fatalf("auth_mode 为 proxy 时必须配置 trusted_proxies")
+	}
+}
+
+func proxyAuthEnabled() bool {
+	return config.AuthMode == "proxy"
+}
+
+func proxyHeader() string {
+	if config.ProxyHeader != "" {
+		return config.ProxyHeader
+	}
+	return "X-Forwarded-User"
+}
+
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// 来自受信任代理时，从 X-Forwarded-For 中自右向左取第一个非代理地址
+func forwardedClientIP(r *http.Request, remote string) string {
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+	}
+	return remote
+}
+
+// 信任代理传入的用户名，仅接受来自受信任代理地址的请求
+func proxyUser(r *http.Request) (UserInfo, bool) {
+	if !isTrustedProxy(remoteIP(r)) {
+		return UserInfo{}, false
+	}
+	username := strings.TrimSpace(r.Header.Get(proxyHeader()))
+	if username == "" {
+		return UserInfo{}, false
+	}
+	return resolveUser(r, "proxy:"+username)
+}
+
+// 代理用户的角色：与本地账号同名时沿用其角色，否则按 proxy_admin_users 判断
+func proxyUserRole(username string) string {
+	if account, ok := findAccount(username); ok {
+		return account.Role
+	}
+	if slices.Contains(config.ProxyAdminUsers, username) {
+		return roleAdmin
+	}
+	return roleViewer
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// 超过该时长未再失败则重新计数
+	loginFailureWindow = 15 * time.Minute
+	// 单次锁定的最长时间
+	loginMaxLockout = time.Hour
+)
+
+type loginAttempt struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+var (
+	loginAttempts   = make(map[string]*loginAttempt)
+	loginAttemptsMu sync.Mutex
+)
+
+// 连续失败多少次后开始锁定，默认5次
+func loginMaxAttempts() int {
+	if config.LoginMaxAttempts > 0 {
+		return config.LoginMaxAttempts
+	}
+	return 5
+}
+
+// 首次锁定时长，之后每次失败翻倍，默认60秒
+func loginLockoutBase() time.Duration {
+	if config.LoginLockoutSeconds > 0 {
+		return time.Duration(config.LoginLockoutSeconds) * time.Second
+	}
+	return time.Minute
+}
+
+// 返回各计数键中最长的剩余锁定时间，未锁定时为0
+func loginLockedFor(keys ...string) time.Duration {
+	loginAttemptsMu.Lock()
+	defer loginAttemptsMu.Unlock()
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		if attempt, ok := loginAttempts[key]; ok && attempt.lockedUntil.After(now) {
+			if d := attempt.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// 记录一次失败，超过阈值后按指数退避锁定
+func recordLoginFailure(keys ...string) {
+	loginAttemptsMu.Lock()
+	defer loginAttemptsMu.Unlock()
+	now := time.Now()
+
+	// 清理过期的计数，避免内存无限增长
+	for key, attempt := range loginAttempts {
+		if now.Sub(attempt.lastFailure) > loginFailureWindow && !attempt.lockedUntil.After(now) {
+			delete(loginAttempts, key)
+		}
+	}
+
+	for _, key := range keys {
+		attempt, ok := loginAttempts[key]
+		if !ok {
+			attempt = &loginAttempt{}
+			loginAttempts[key] = attempt
+		}
+		attempt.failures++
+		attempt.lastFailure = now
+		if over := attempt.failures - loginMaxAttempts(); over >= 0 {
+			lockout := loginLockoutBase()
+			for i := 0; i < over && lockout < loginMaxLockout; i++ {
+				lockout *= 2
+			}
+			if lockout > loginMaxLockout {
+				lockout = loginMaxLockout
+			}
+			attempt.lockedUntil = now.Add(lockout)
+		}
+	}
+}
+
+// 登录成功后清除计数
+func resetLoginFailures(keys ...string) {
+	loginAttemptsMu.Lock()
+	defer loginAttemptsMu.Unlock()
+	for _, key := range keys {
+		delete(loginAttempts, key)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// 连续失败达到阈值后锁定，之后每次失败锁定时间翻倍
+func TestLoginLockout(t *testing.T) {
+	keys := []string{"ip:lockout-test", "user:lockout-test"}
+	t.Cleanup(func() { resetLoginFailures(keys...) })
+	for range loginMaxAttempts() - 1 {
+		recordLoginFailure(keys...)
+	}
+	if wait := loginLockedFor(keys...); wait != 0 {
+		t.Fatalf("未达到阈值时锁定了 %s", wait)
+	}
+	recordLoginFailure(keys...)
+	if wait := loginLockedFor(keys[1]); wait <= 0 || wait > loginLockoutBase() {
+		t.Errorf("首次锁定 %s，期望不超过 %s", wait, loginLockoutBase())
+	}
+	recordLoginFailure(keys...)
+	if wait := loginLockedFor(keys[1]); wait <= loginLockoutBase() || wait > 2*loginLockoutBase() {
+		t.Errorf("再次失败后锁定 %s，期望翻倍", wait)
+	}
+	resetLoginFailures(keys...)
+	if wait := loginLockedFor(keys...); wait != 0 {
+		t.Errorf("清除计数后仍锁定 %s", wait)
+	}
+}
+
+// 锁定期间即使密码正确也拒绝登录
+func TestLoginHandlerLockout(t *testing.T) {
+	saved := config.Password
+	config.Password = "secret"
+	t.Cleanup(func() {
+		config.Password = saved
+		resetLoginFailures("ip:198.51.100.7")
+	})
+	login := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{"password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "198.51.100.7:40000"
+		rec := httptest.NewRecorder()
+		loginHandler(rec, req)
+		return rec
+	}
+	for range loginMaxAttempts() {
+		if rec := login("wrong"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("密码错误返回 %d，期望 401", rec.Code)
+		}
+	}
+	rec := login("secret")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("锁定期间返回 %d，Retry-After = %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	resetLoginFailures("ip:198.51.100.7")
+	if rec := login("secret"); rec.Code != http.StatusFound {
+		t.Errorf("解除锁定后登录返回 %d", rec.Code)
+	}
+}
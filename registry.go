@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const sessionsFileName = "conf/sessions.json"
+
+// 服务端登录会话记录，认证令牌中的 sid 必须在此登记才有效
+type SessionRecord struct {
+	ID        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var (
+	sessionRecords   = make(map[string]SessionRecord)
+	sessionRecordsMu sync.RWMutex
+)
+
+// 加载已登记的会话，重启后已登录用户无需重新登录
+func loadSessionRecords() {
+	content, err := os.ReadFile(sessionsFileName)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("无法读取会话文件 %s: %v", sessionsFileName, err)
+		return
+	}
+	var records []SessionRecord
+	if err := json.Unmarshal(content, &records); err != nil {
+		log.Printf("无法解析会话文件 %s: %v", sessionsFileName, err)
+		return
+	}
+	now := time.Now()
+	for _, record := range records {
+		if record.ExpiresAt.After(now) {
+			sessionRecords[record.ID] = record
+		}
+	}
+}
+
+// 写回会话文件，调用方需持有锁
+func saveSessionRecords() {
+	records := make([]SessionRecord, 0, len(sessionRecords))
+	for _, record := range sessionRecords {
+		records = append(records, record)
+	}
+	content, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		log.Printf("无法序列化会话记录: %v", err)
+		return
+	}
+	if err := os.WriteFile(sessionsFileName, content, 0600); err != nil {
+		log.Printf("无法写入会话文件 %s: %v", sessionsFileName, err)
+	}
+}
+
+// 登记新会话并清理已过期的记录
+func createSessionRecord(r *http.Request, subject string, ttl time.Duration) SessionRecord {
+	now := time.Now()
+	record := SessionRecord{
+		ID:        hex.EncodeToString(generateRandomKey(16)),
+		Subject:   subject,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	sessionRecordsMu.Lock()
+	defer sessionRecordsMu.Unlock()
+	for id, old := range sessionRecords {
+		if !old.ExpiresAt.After(now) {
+			delete(sessionRecords, id)
+		}
+	}
+	sessionRecords[record.ID] = record
+	saveSessionRecords()
+	return record
+}
+
+// 会话是否仍然有效
+func sessionActive(id, subject string) bool {
+	sessionRecordsMu.RLock()
+	defer sessionRecordsMu.RUnlock()
+	record, ok := sessionRecords[id]
+	return ok && record.Subject == subject && record.ExpiresAt.After(time.Now())
+}
+
+// 撤销单个会话
+func revokeSession(id string) bool {
+	sessionRecordsMu.Lock()
+	defer sessionRecordsMu.Unlock()
+	if _, ok := sessionRecords[id]; !ok {
+		return false
+	}
+	delete(sessionRecords, id)
+	saveSessionRecords()
+	return true
+}
+
+// 撤销某个用户的全部会话，返回撤销数量
+func revokeSubjectSessions(subject string) int {
+	sessionRecordsMu.Lock()
+	defer sessionRecordsMu.Unlock()
+	count := 0
+	for id, record := range sessionRecords {
+		if record.Subject == subject {
+			delete(sessionRecords, id)
+			count++
+		}
+	}
+	if count > 0 {
+		saveSessionRecords()
+	}
+	return count
+}
+
+// 当前有效的会话列表，按登录时间倒序
+func listSessionRecords() []SessionRecord {
+	sessionRecordsMu.RLock()
+	defer sessionRecordsMu.RUnlock()
+	now := time.Now()
+	var records []SessionRecord
+	for _, record := range sessionRecords {
+		if record.ExpiresAt.After(now) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	return records
+}
+
+// 客户端IP，经受信任代理转发时取真实来源地址
+func clientIP(r *http.Request) string {
+	return forwardedClientIP(r, remoteIP(r))
+}
+
+// 直接连接的对端IP
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	s3Algorithm = "AWS4-HMAC-SHA256"
+	// 所有请求都没有请求体，使用空字符串的 SHA-256
+	s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// 每次范围请求读取的数据量，连续读取时逐次翻倍，减少大文件的请求次数
+	s3MinReadAhead = 1 << 20
+	s3MaxReadAhead = 64 << 20
+)
+
+// 兼容 S3 协议的对象存储，如 AWS S3、MinIO，对象键为 prefix 加上图片路径
+type s3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+func newS3Storage(cfg S3Config) (*s3Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("必须设置 s3.endpoint 与 s3.bucket")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" || endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("无效的 s3.endpoint: %s", cfg.Endpoint)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return &s3Storage{
+		endpoint:  endpoint,
+		region:    region,
+		bucket:    cfg.Bucket,
+		prefix:    prefix,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		pathStyle: cfg.PathStyle == "true",
+		client:    &http.Client{Transport: transport},
+	}, nil
+}
+
+// 图片路径对应的对象键
+func (s *s3Storage) key(name string) string {
+	if name == "." {
+		return s.prefix
+	}
+	return s.prefix + name
+}
+
+// 列出目录时使用的前缀
+func (s *s3Storage) dirPrefix(name string) string {
+	if name == "." {
+		return s.prefix
+	}
+	return s.prefix + name + "/"
+}
+
+// 请求地址，path_style 为 true 时使用 endpoint/bucket/key，否则使用 bucket.endpoint/key
+func (s *s3Storage) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	u.Path = "/" + key
+	if s.pathStyle {
+		u.Path = "/" + s.bucket
+		if key != "" {
+			u.Path += "/" + key
+		}
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = s3CanonicalQuery(query)
+	return &u
+}
+
+func (s *s3Storage) do(method, key string, query url.Values, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(key, query).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// 按 AWS Signature Version 4 签名请求，签名包含 host、range 与全部 x-amz- 请求头
+func (s *s3Storage) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3EmptyPayloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := slices.Sorted(maps.Keys(headers))
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3EmptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{date, s.region, "s3", "aws4_request"} {
+		key = s3HMAC(key, part)
+	}
+	signature := hex.EncodeToString(s3HMAC(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.accessKey, scope, signedHeaders, signature))
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// 按签名要求转义：只保留字母、数字与 -_.~，路径中的 / 不转义
+func s3Escape(value string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !escapeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// 按参数名排序的查询字符串，同时用作请求地址与签名
+func s3CanonicalQuery(query url.Values) string {
+	var parts []string
+	for _, name := range slices.Sorted(maps.Keys(query)) {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+type s3ErrorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// 对象存储返回的错误，404 转换为 fs.ErrNotExist
+func s3ResponseError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fs.ErrNotExist
+	}
+	var body s3ErrorResponse
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if body.Code != "" {
+		return fmt.Errorf("对象存储返回 %s: %s %s", resp.Status, body.Code, body.Message)
+	}
+	return fmt.Errorf("对象存储返回 %s", resp.Status)
+}
+
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+// 调用 ListObjectsV2 列出对象
+func (s *s3Storage) listObjects(query url.Values) (s3ListResult, error) {
+	var result s3ListResult
+	query.Set("list-type", "2")
+	resp, err := s.do(http.MethodGet, "", query, nil)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return result, s3ResponseError(resp)
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	return result, err
+}
+
+// 列出目录，以 / 分隔的公共前缀作为子目录
+func (s *s3Storage) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := s.dirPrefix(name)
+	var entries []fs.DirEntry
+	found := false
+	token := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "delimiter": {"/"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		result, err := s.listObjects(query)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		for _, p := range result.CommonPrefixes {
+			found = true
+			if child := strings.TrimSuffix(strings.TrimPrefix(p.Prefix, prefix), "/"); child != "" {
+				entries = append(entries, fs.FileInfoToDirEntry(&s3FileInfo{name: child, dir: true}))
+			}
+		}
+		for _, c := range result.Contents {
+			found = true
+			// 跳过部分工具创建的目录占位对象
+			child := strings.TrimPrefix(c.Key, prefix)
+			if child == "" || strings.Contains(child, "/") {
+				continue
+			}
+			entries = append(entries, fs.FileInfoToDirEntry(&s3FileInfo{name: child, size: c.Size, modTime: c.LastModified}))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	if !found && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// 读取对象信息，对象不存在但有以该名称为前缀的对象时视为目录
+func (s *s3Storage) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &s3FileInfo{name: ".", dir: true}, nil
+	}
+	resp, err := s.do(http.MethodHead, s.key(name), nil, nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+		return &s3FileInfo{name: path.Base(name), size: resp.ContentLength, modTime: modTime}, nil
+	case http.StatusNotFound:
+	default:
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: s3ResponseError(resp)}
+	}
+	result, err := s.listObjects(url.Values{"prefix": {s.dirPrefix(name)}, "max-keys": {"1"}})
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if len(result.Contents) == 0 && len(result.CommonPrefixes) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &s3FileInfo{name: path.Base(name), dir: true}, nil
+}
+
+func (s *s3Storage) Open(name string) (fs.File, error) {
+	info, err := s.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &s3Dir{storage: s, name: name, info: info}, nil
+	}
+	return &s3File{storage: s, key: s.key(name), info: info}, nil
+}
+
+// 读取整个对象，只需一次请求
+func (s *s3Storage) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	resp, err := s.do(http.MethodGet, s.key(name), nil, nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: s3ResponseError(resp)}
+	}
+	return io.ReadAll(resp.Body)
+}
+
+type s3FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *s3FileInfo) Name() string       { return fi.name }
+func (fi *s3FileInfo) Size() int64        { return fi.size }
+func (fi *s3FileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *s3FileInfo) IsDir() bool        { return fi.dir }
+func (fi *s3FileInfo) Sys() any           { return nil }
+func (fi *s3FileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// 对象存储中的目录，首次读取时列出其中的对象
+type s3Dir struct {
+	storage *s3Storage
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	loaded  bool
+}
+
+func (d *s3Dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *s3Dir) Close() error               { return nil }
+
+func (d *s3Dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("是目录")}
+}
+
+func (d *s3Dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.loaded {
+		entries, err := d.storage.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.loaded = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// 对象存储中的文件，通过范围请求按需读取，连续读取时复用同一个响应
+type s3File struct {
+	storage *s3Storage
+	key     string
+	info    fs.FileInfo
+	offset  int64 // Read 与 Seek 使用的位置
+
+	mu      sync.Mutex
+	body    io.ReadCloser
+	bodyPos int64 // 响应中下一个字节在对象中的位置
+	bodyEnd int64 // 响应数据的结束位置（不含）
+	window  int64
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *s3File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closeBody()
+	return nil
+}
+
+func (f *s3File) closeBody() {
+	if f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+}
+
+// 从 off 开始发起范围请求，紧接上一次请求的结尾继续读取时加大读取量
+func (f *s3File) openRange(off int64, want int) error {
+	window := int64(s3MinReadAhead)
+	if off == f.bodyEnd && f.window > 0 {
+		window = min(f.window*2, s3MaxReadAhead)
+	}
+	window = max(window, int64(want))
+	f.closeBody()
+	end := min(off+window, f.info.Size())
+	resp, err := f.storage.do(http.MethodGet, f.key, nil, http.Header{
+		"Range": {fmt.Sprintf("bytes=%d-%d", off, end-1)},
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && off == 0:
+		// 不支持范围请求时返回整个对象
+		end = f.info.Size()
+	default:
+		defer resp.Body.Close()
+		return s3ResponseError(resp)
+	}
+	f.body, f.bodyPos, f.bodyEnd, f.window = resp.Body, off, end, window
+	return nil
+}
+
+func (f *s3File) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("偏移量不能为负数")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for n < len(p) && off < f.info.Size() {
+		if f.body == nil || f.bodyPos != off || f.bodyPos >= f.bodyEnd {
+			if err := f.openRange(off, len(p)-n); err != nil {
+				return n, err
+			}
+		}
+		want := min(int64(len(p)-n), f.bodyEnd-f.bodyPos)
+		m, err := io.ReadFull(f.body, p[n:n+int(want)])
+		n += m
+		off += int64(m)
+		f.bodyPos += int64(m)
+		if err != nil {
+			f.closeBody()
+			return n, err
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.offset >= f.info.Size() {
+		return 0, io.EOF
+	}
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, errors.New("无效的 whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("偏移量不能为负数")
+	}
+	f.offset = offset
+	return offset, nil
+}
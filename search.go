@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+const (
+	searchMaxQuery = 100 // 搜索词最多字符数
+	searchMaxTerms = 8   // 最多使用的关键词数量，其余忽略
+	searchMaxLimit = 100 // 每页最多结果数
+)
+
+// 搜索结果，分类与图片共用，Type 为 category 或 image
+type SearchResult struct {
+	Type            string
+	Name            string // 分类目录名，或图片相对所在分类的路径
+	Title           string // 分类标题，图片为空
+	Category        string // 分类路径，图片为所在分类的路径
+	EncodedCategory string
+	CategoryTitle   string   // 图片所在分类的标题
+	EncodedName     string   // 逐段转义的图片路径，分类为空
+	CoverImage      string   // 分类封面
+	Tags            []string // 分类标签
+	Kind            string   // 图片的媒体类型
+	score           int
+}
+
+// 可搜索的文本，含有汉字时同时保存全拼与拼音首字母
+type searchField struct {
+	text     string
+	full     string
+	initials string
+	bonus    int // 命中时额外加分，使分类名称与标题排在标签、文件名之前
+}
+
+type searchDoc struct {
+	result SearchResult
+	fields []searchField
+}
+
+// 一个顶层分类中的全部分类与图片，分类信息变化后重新生成
+type searchEntry struct {
+	category Category
+	docs     []searchDoc
+}
+
+// 查询中的一个关键词，full 为去掉符号后的拼音，用于匹配全拼与首字母
+type searchTerm struct {
+	text string
+	full string
+	han  bool
+}
+
+var (
+	// 索引只整体替换不原地修改，读取方拿到的 map 可以直接使用
+	searchIndex   = make(map[string]searchEntry)
+	searchDirty   = make(map[string]bool) // 收到文件变更、需要重新生成的顶层分类
+	searchIndexMu sync.RWMutex
+	// 合并短时间内的多次更新请求，同一时间只有一个后台任务生成索引
+	searchRefresh = make(chan struct{}, 1)
+	pinyinArgs    = pinyin.NewArgs()
+)
+
+// 启动后台索引任务，扫描整个图片存储可能较慢，完成前搜索结果不完整
+func initSearch() {
+	go func() {
+		for range searchRefresh {
+			updateSearchIndex()
+		}
+	}()
+	refreshSearchIndex()
+}
+
+// 请求后台更新索引，只重新生成发生变化的顶层分类
+func refreshSearchIndex() {
+	select {
+	case searchRefresh <- struct{}{}:
+	default:
+	}
+}
+
+// 顶层分类中的文件有变化，分类信息不变时也需要重新生成，如子目录中的图片改名
+func invalidateSearchIndex(name string) {
+	searchIndexMu.Lock()
+	searchDirty[name] = true
+	searchIndexMu.Unlock()
+	refreshSearchIndex()
+}
+
+func updateSearchIndex() {
+	categories := getCategories()
+	searchIndexMu.Lock()
+	current, dirty := searchIndex, searchDirty
+	searchDirty = make(map[string]bool)
+	searchIndexMu.Unlock()
+
+	index := make(map[string]searchEntry, len(categories))
+	changed := len(current) != len(categories)
+	total := 0
+	for _, category := range categories {
+		entry, ok := current[category.Path]
+		if !ok || dirty[category.Path] || !sameCategory(entry.category, category) {
+			entry = searchEntry{category: category, docs: buildSearchDocs(category)}
+			changed = true
+		}
+		index[category.Path] = entry
+		total += len(entry.docs)
+	}
+
+	searchIndexMu.Lock()
+	searchIndex = index
+	searchIndexMu.Unlock()
+	if changed {
+		log.Printf("搜索索引已更新，共 %d 项", total)
+	}
+}
+
+// 递归收集分类、未隐藏的子分类以及其中的媒体文件
+func buildSearchDocs(category Category) []searchDoc {
+	var docs []searchDoc
+	var walk func(category Category)
+	walk = func(category Category) {
+		docs = append(docs, categoryDoc(category))
+		images, children, err := readCategoryDir(category.Path)
+		if err != nil {
+			log.Printf("无法读取分类 %s: %v", category.Path, err)
+			return
+		}
+		for _, image := range images {
+			docs = append(docs, imageDoc(category, image))
+		}
+		for _, child := range children {
+			walk(child)
+		}
+	}
+	walk(category)
+	return docs
+}
+
+func categoryDoc(category Category) searchDoc {
+	fields := []searchField{newSearchField(category.Name, 10)}
+	if category.Title != category.Name {
+		fields = append(fields, newSearchField(category.Title, 10))
+	}
+	for _, tag := range category.Tags {
+		fields = append(fields, newSearchField(tag, 0))
+	}
+	return searchDoc{
+		result: SearchResult{
+			Type:            "category",
+			Name:            category.Name,
+			Title:           category.Title,
+			Category:        category.Path,
+			EncodedCategory: category.EncodedName,
+			CoverImage:      category.CoverImage,
+			Tags:            category.Tags,
+		},
+		fields: fields,
+	}
+}
+
+// 图片按去掉扩展名的文件名匹配，避免搜索 jpg 时命中所有图片
+func imageDoc(category Category, image Image) searchDoc {
+	return searchDoc{
+		result: SearchResult{
+			Type:            "image",
+			Name:            image.Name,
+			Category:        category.Path,
+			EncodedCategory: category.EncodedName,
+			CategoryTitle:   category.Title,
+			EncodedName:     image.EncodedName,
+			Kind:            image.Kind,
+		},
+		fields: []searchField{newSearchField(strings.TrimSuffix(image.Name, path.Ext(image.Name)), 0)},
+	}
+}
+
+func newSearchField(s string, bonus int) searchField {
+	field := searchField{text: strings.ToLower(s), bonus: bonus}
+	if full, initials, han := toPinyin(field.text); han {
+		field.full, field.initials = full, initials
+	}
+	return field
+}
+
+// 将汉字转换为不带声调的拼音，保留字母与数字，去掉空格与符号；多音字只取常用读音
+func toPinyin(s string) (full, initials string, han bool) {
+	var fullBuf, initialsBuf strings.Builder
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 && py[0] != "" {
+				fullBuf.WriteString(py[0])
+				initialsBuf.WriteByte(py[0][0])
+				han = true
+				continue
+			}
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			fullBuf.WriteRune(r)
+			initialsBuf.WriteRune(r)
+		}
+	}
+	return fullBuf.String(), initialsBuf.String(), han
+}
+
+// 拆分查询为关键词，忽略大小写
+func parseSearchTerms(query string) []searchTerm {
+	var terms []searchTerm
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if len(terms) == searchMaxTerms {
+			break
+		}
+		full, _, han := toPinyin(word)
+		terms = append(terms, searchTerm{text: word, full: full, han: han})
+	}
+	return terms
+}
+
+// 关键词与文本的匹配得分，依次尝试原文、全拼、拼音首字母与模糊匹配，不匹配时返回0。
+// 拼音串较长，按字符顺序模糊匹配容易误中，只对原文做模糊匹配
+func (field searchField) match(term searchTerm) int {
+	score := 0
+	switch {
+	case field.text == term.text:
+		score = 100
+	case strings.HasPrefix(field.text, term.text):
+		score = 80
+	case strings.Contains(field.text, term.text):
+		score = 60
+	case field.full != "" && term.full != "" && strings.HasPrefix(field.full, term.full):
+		score = 50
+	case field.full != "" && term.full != "" && strings.Contains(field.full, term.full):
+		score = 40
+	case field.initials != "" && !term.han && term.full != "" && strings.HasPrefix(field.initials, term.full):
+		score = 35
+	case field.initials != "" && !term.han && term.full != "" && strings.Contains(field.initials, term.full):
+		score = 25
+	case utf8.RuneCountInString(term.text) >= 2 && fuzzyMatch(field.text, term.text):
+		score = 10
+	default:
+		return 0
+	}
+	return score + field.bonus
+}
+
+// 关键词中的字符是否按顺序出现在文本中，如 jpn 匹配 japan
+func fuzzyMatch(text, term string) bool {
+	for _, r := range term {
+		i := strings.IndexRune(text, r)
+		if i < 0 {
+			return false
+		}
+		text = text[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// 所有关键词都命中时返回各关键词最高得分之和，否则返回0
+func (doc searchDoc) score(terms []searchTerm) int {
+	total := 0
+	for _, term := range terms {
+		best := 0
+		for _, field := range doc.fields {
+			best = max(best, field.match(term))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+// 在当前用户可访问的分类中搜索，结果按得分排序，得分相同时分类排在图片之前
+func searchCategories(user UserInfo, query string) []SearchResult {
+	terms := parseSearchTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}
+	}
+	searchIndexMu.RLock()
+	index := searchIndex
+	searchIndexMu.RUnlock()
+
+	results := []SearchResult{}
+	for _, entry := range index {
+		for _, doc := range entry.docs {
+			if !categoryAllowed(user, doc.result.Category) {
+				continue
+			}
+			if score := doc.score(terms); score > 0 {
+				result := doc.result
+				result.score = score
+				results = append(results, result)
+			}
+		}
+	}
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		if a.Type != b.Type {
+			if a.Type == "category" {
+				return -1
+			}
+			return 1
+		}
+		if c := naturalCompare(a.Category, b.Category); c != 0 {
+			return c
+		}
+		return naturalCompare(a.Name, b.Name)
+	})
+	return results
+}
+
+// 返回指定页的结果与总页数，超出末页时返回空结果
+func pageSearchResults(results []SearchResult, page, limit int) ([]SearchResult, int) {
+	pages := (len(results) + limit - 1) / limit
+	// 先限制页码再计算偏移，避免页码过大时乘法溢出
+	page = min(page, pages+1)
+	start := min((page-1)*limit, len(results))
+	end := min(start+limit, len(results))
+	return results[start:end], pages
+}
+
+func searchJson(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeJSONError(w, http.StatusBadRequest, "请输入搜索关键词")
+		return
+	}
+	if utf8.RuneCountInString(query) > searchMaxQuery {
+		writeJSONError(w, http.StatusBadRequest, "搜索关键词过长")
+		return
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+	limit = min(limit, searchMaxLimit)
+
+	results := searchCategories(currentUser(r), query)
+	current, pages := pageSearchResults(results, page, limit)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   query,
+		"results": current,
+		"page":    page,
+		"limit":   limit,
+		"total":   len(results),
+		"pages":   pages,
+	})
+}
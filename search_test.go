@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPageSearchResults(t *testing.T) {
+	results := make([]SearchResult, 45)
+	tests := []struct {
+		page, limit, n, pages int
+	}{
+		{1, 20, 20, 3},
+		{3, 20, 5, 3},
+		{4, 20, 0, 3},
+		{100000000000000001, 20, 0, 3},
+		{math.MaxInt, 100, 0, 1},
+	}
+	for _, tt := range tests {
+		current, pages := pageSearchResults(results, tt.page, tt.limit)
+		if len(current) != tt.n || pages != tt.pages {
+			t.Errorf("page=%d limit=%d: 返回 %d 项、%d 页，期望 %d 项、%d 页", tt.page, tt.limit, len(current), pages, tt.n, tt.pages)
+		}
+	}
+	if current, pages := pageSearchResults(nil, 5, 20); len(current) != 0 || pages != 0 {
+		t.Errorf("没有结果时返回 %d 项、%d 页", len(current), pages)
+	}
+}
+
+func TestSearchJson(t *testing.T) {
+	for _, tt := range []struct {
+		query  string
+		status int
+	}{
+		{"?q=", http.StatusBadRequest},
+		{"?q=" + strings.Repeat("a", searchMaxQuery+1), http.StatusBadRequest},
+		{"?q=trip&page=100000000000000001&limit=100000000000000001", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		searchJson(rec, httptest.NewRequest(http.MethodGet, "/api/search"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: 返回 %d，期望 %d", tt.query, rec.Code, tt.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q", tt.query, ct)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: 响应不是 JSON: %v", tt.query, err)
+		}
+	}
+}
+
+func TestSearchFieldMatch(t *testing.T) {
+	field := newSearchField("风景照片", 0)
+	for _, tt := range []struct {
+		query string
+		match bool
+	}{
+		{"风景", true},
+		{"fengjing", true},
+		{"fjzp", true},
+		{"丰景", true}, // 同音字
+		{"jpn", false},
+		{"山水", false},
+	} {
+		terms := parseSearchTerms(tt.query)
+		if got := field.match(terms[0]) > 0; got != tt.match {
+			t.Errorf("%s: 匹配结果 %v，期望 %v", tt.query, got, tt.match)
+		}
+	}
+	if !fuzzyMatch("japan", "jpn") || fuzzyMatch("japan", "npj") {
+		t.Error("模糊匹配应按字符顺序")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+var store *sessions.CookieStore
+
+// 根据配置初始化会话存储
+func initSessionStore() {
+	var keyPairs [][]byte
+	for i, key := range config.SessionKeys {
+		hashKey, err := hex.DecodeString(key.HashKey)
+		if err != nil || len(hashKey) == 0 {
+			log.Fatalf("session_keys[%d].hash_key 无效: %v", i, err)
+		}
+		var blockKey []byte
+		if key.BlockKey != "" {
+			blockKey, err = hex.DecodeString(key.BlockKey)
+			if err != nil {
+				log.Fatalf("session_keys[%d].block_key 无效: %v", i, err)
+			}
+			switch len(blockKey) {
+			case 16, 24, 32:
+			default:
+				log.Fatalf("session_keys[%d].block_key 长度必须为16、24或32字节", i)
+			}
+		}
+		keyPairs = append(keyPairs, hashKey, blockKey)
+	}
+
+	store = sessions.NewCookieStore(keyPairs...)
+	store.Options = &sessions.Options{
+		Path:     "/",
+		Domain:   config.CookieDomain,
+		MaxAge:   cookieMaxAge(),
+		Secure:   cookieSecure(),
+		HttpOnly: true,
+		SameSite: cookieSameSite(),
+	}
+	store.MaxAge(store.Options.MaxAge)
+}
+
+// 生成一组新的会话密钥
+func newSessionKey() SessionKey {
+	return SessionKey{
+		HashKey:  hex.EncodeToString(generateRandomKey(64)),
+		BlockKey: hex.EncodeToString(generateRandomKey(32)),
+	}
+}
+
+// cookie有效期（秒），默认1小时
+func cookieMaxAge() int {
+	if config.CookieMaxAge > 0 {
+		return config.CookieMaxAge
+	}
+	return 3600
+}
+
+// 仅在明确配置为false时关闭Secure，开发环境可设为false，生产环境必须开启
+func cookieSecure() bool {
+	return config.CookieSecure != "false"
+}
+
+func cookieSameSite() http.SameSite {
+	switch strings.ToLower(config.CookieSameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 新增的密钥排在首位用于签发，旧密钥保留时此前的会话仍然有效
+func TestSessionKeyRotation(t *testing.T) {
+	saved := config.SessionKeys
+	t.Cleanup(func() {
+		config.SessionKeys = saved
+		initSessionStore()
+	})
+	oldKey, newKey := newSessionKey(), newSessionKey()
+	config.SessionKeys = []SessionKey{oldKey}
+	initSessionStore()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, _ := store.Get(req, "session-name")
+	session.Values["state"] = "abc"
+	if err := session.Save(req, rec); err != nil {
+		t.Fatal(err)
+	}
+	state := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		addCookies(req, rec.Result())
+		session, _ := store.Get(req, "session-name")
+		value, _ := session.Values["state"].(string)
+		return value
+	}
+
+	config.SessionKeys = []SessionKey{newKey, oldKey}
+	initSessionStore()
+	if got := state(); got != "abc" {
+		t.Errorf("保留旧密钥时读取到 %q", got)
+	}
+	config.SessionKeys = []SessionKey{newKey}
+	initSessionStore()
+	if got := state(); got != "" {
+		t.Errorf("移除旧密钥后仍读取到 %q", got)
+	}
+}
+
+// 未配置的密钥自动生成，已有密钥保持不变
+func TestEnsureSecrets(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.AuthSecret = ""
+	config.SessionKeys = nil
+	if !ensureSecrets() || config.AuthSecret == "" || len(config.SessionKeys) != 1 {
+		t.Fatalf("未生成密钥: %q %v", config.AuthSecret, config.SessionKeys)
+	}
+	secret, keys := config.AuthSecret, config.SessionKeys
+	if ensureSecrets() || config.AuthSecret != secret || config.SessionKeys[0] != keys[0] {
+		t.Error("已有密钥不应被替换")
+	}
+}
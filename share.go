@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const sharesFileName = "conf/shares.json"
+
+// 分类分享链接
+type ShareLink struct {
+	ID           string    `json:"id"`
+	Category     string    `json:"category"`
+	CreatedBy    string    `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxViews     int       `json:"max_views"` // 0 表示不限次数
+	Views        int       `json:"views"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+}
+
+var (
+	shareLinks   = make(map[string]*ShareLink)
+	shareLinksMu sync.RWMutex
+)
+
+func loadShareLinks() {
+	content, err := os.ReadFile(sharesFileName)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("无法读取分享文件 %s: %v", sharesFileName, err)
+		return
+	}
+	var links []*ShareLink
+	if err := json.Unmarshal(content, &links); err != nil {
+		log.Printf("无法解析分享文件 %s: %v", sharesFileName, err)
+		return
+	}
+	for _, link := range links {
+		shareLinks[link.ID] = link
+	}
+}
+
+// 写回分享文件，调用方需持有锁
+func saveShareLinks() {
+	links := make([]*ShareLink, 0, len(shareLinks))
+	for _, link := range shareLinks {
+		links = append(links, link)
+	}
+	content, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		log.Printf("无法序列化分享链接: %v", err)
+		return
+	}
+	if err := os.WriteFile(sharesFileName, content, 0600); err != nil {
+		log.Printf("无法写入分享文件 %s: %v", sharesFileName, err)
+	}
+}
+
+// 链接是否仍可使用，checkViews 为 true 时同时检查访问次数
+func (l *ShareLink) usable(checkViews bool) bool {
+	if !l.ExpiresAt.After(time.Now()) {
+		return false
+	}
+	return !checkViews || l.MaxViews <= 0 || l.Views < l.MaxViews
+}
+
+// 分享链接中的令牌，格式为 id.签名，更换 auth_secret 会使所有链接失效
+func shareToken(id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(authSignature("share:" + id)[:12])
+}
+
+func parseShareToken(token string) (string, bool) {
+	id, _, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(token), []byte(shareToken(id))) {
+		return "", false
+	}
+	return id, true
+}
+
+func findShareLink(id string) (ShareLink, bool) {
+	shareLinksMu.RLock()
+	defer shareLinksMu.RUnlock()
+	link, ok := shareLinks[id]
+	if !ok {
+		return ShareLink{}, false
+	}
+	return *link, true
+}
+
+func createShareLink(category, createdBy string, ttl time.Duration, maxViews int, password string) (ShareLink, error) {
+	link := ShareLink{
+		ID:        hex.EncodeToString(generateRandomKey(16)),
+		Category:  category,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(ttl),
+		MaxViews:  maxViews,
+	}
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return ShareLink{}, err
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	shareLinksMu.Lock()
+	defer shareLinksMu.Unlock()
+	for id, old := range shareLinks {
+		if !old.usable(false) {
+			delete(shareLinks, id)
+		}
+	}
+	shareLinks[link.ID] = &link
+	saveShareLinks()
+	return link, nil
+}
+
+func revokeShareLink(id string) {
+	shareLinksMu.Lock()
+	defer shareLinksMu.Unlock()
+	if _, ok := shareLinks[id]; ok {
+		delete(shareLinks, id)
+		saveShareLinks()
+	}
+}
+
+// 记录一次访问，超出次数限制时返回 false
+func consumeShareView(id string) bool {
+	shareLinksMu.Lock()
+	defer shareLinksMu.Unlock()
+	link, ok := shareLinks[id]
+	if !ok || !link.usable(true) {
+		return false
+	}
+	link.Views++
+	saveShareLinks()
+	return true
+}
+
+func listShareLinks() []ShareLink {
+	shareLinksMu.RLock()
+	defer shareLinksMu.RUnlock()
+	var links []ShareLink
+	for _, link := range shareLinks {
+		if link.usable(false) {
+			links = append(links, *link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt.After(links[j].CreatedAt)
+	})
+	return links
+}
+
+// 根据分享cookie还原访客，仅允许访问分享分类的页面、接口与图片
+func shareUser(r *http.Request) (UserInfo, bool) {
+	cookie, err := r.Cookie("share")
+	if err != nil {
+		return UserInfo{}, false
+	}
+	claims, err := parseAuthToken(cookie.Value)
+	if err != nil {
+		return UserInfo{}, false
+	}
+	id, ok := strings.CutPrefix(claims.Subject, "share:")
+	if !ok {
+		return UserInfo{}, false
+	}
+	link, ok := findShareLink(id)
+	if !ok || !link.usable(false) || !shareAllowsPath(link.Category, r.URL.Path) {
+		return UserInfo{}, false
+	}
+	return UserInfo{Provider: "share", ShareCategory: link.Category}, true
+}
+
+// 请求路径是否属于分享的分类
+func shareAllowsPath(category, p string) bool {
+	var target string
+	switch {
+	case strings.HasPrefix(p, "/category/"):
+		target, _ = resolveCategoryPath(p[len("/category/"):])
+	case strings.HasPrefix(p, "/api/category/"):
+		target, _ = resolveCategoryPath(p[len("/api/category/"):])
+	case strings.HasPrefix(p, "/images/"):
+		target = path.Dir(strings.TrimPrefix(path.Clean(p), "/images/"))
+	case strings.HasPrefix(p, "/thumbs/"):
+		target = path.Dir(strings.TrimPrefix(path.Clean(p), "/thumbs/"))
+	case strings.HasPrefix(p, "/api/image/"):
+		target = path.Dir(strings.TrimPrefix(path.Clean(p), "/api/image/"))
+	case strings.HasPrefix(p, "/download/"):
+		target, _ = resolveCategoryPath(strings.TrimSuffix(p[len("/download/"):], ".zip"))
+	default:
+		return false
+	}
+	// 子分类同样可以访问
+	return target == category || strings.HasPrefix(target, category+"/")
+}
+
+// 打开分享链接：校验令牌、有效期、访问次数与密码，通过后写入分享cookie并跳转到分类页面
+func shareHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseShareToken(r.URL.Path[len("/s/"):])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	link, ok := findShareLink(id)
+	if !ok || !link.usable(true) {
+		http.Error(w, "分享链接不存在或已失效", http.StatusGone)
+		return
+	}
+
+	if link.PasswordHash != "" {
+		keys := []string{"ip:" + clientIP(r), "share:" + id}
+		if r.Method != http.MethodPost {
+			renderSharePassword(w, r, link, "")
+			return
+		}
+		if wait := loginLockedFor(keys...); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "密码错误次数过多，请稍后重试", http.StatusTooManyRequests)
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(r.FormValue("password"))) != nil {
+			recordLoginFailure(keys...)
+			w.WriteHeader(http.StatusUnauthorized)
+			renderSharePassword(w, r, link, "密码错误")
+			return
+		}
+		resetLoginFailures(keys...)
+	}
+
+	if !consumeShareView(id) {
+		http.Error(w, "分享链接不存在或已失效", http.StatusGone)
+		return
+	}
+	ttl := time.Until(link.ExpiresAt)
+	http.SetCookie(w, &http.Cookie{
+		Name:     "share",
+		Value:    signAuthToken("share:"+id, "", ttl),
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Path:     "/",
+		Domain:   config.CookieDomain,
+		Secure:   cookieSecure(),
+		SameSite: cookieSameSite(),
+	})
+	http.Redirect(w, r, "/category/"+url.PathEscape(link.Category), http.StatusFound)
+}
+
+func renderSharePassword(w http.ResponseWriter, r *http.Request, link ShareLink, message string) {
+	tmpl := template.Must(template.New("share").Funcs(csrfFuncs(w, r)).Parse(sharePasswordTemplate))
+	tmpl.Execute(w, struct {
+		Config   Config
+		Category string
+		Message  string
+	}{
+		Config:   config,
+		Category: link.Category,
+		Message:  message,
+	})
+}
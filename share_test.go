@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// 只能为当前展示的分类生成分享链接
+func TestCreateShareRequiresVisibleCategory(t *testing.T) {
+	writeImageFile(t, "ShareVisible/a.jpg", testContent(10))
+	writeImageFile(t, "ShareHidden/a.jpg", testContent(10))
+	writeImageFile(t, "ShareHidden/album.yaml", []byte("hidden: true\n"))
+	categories, err := readCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setCategories(categories)
+	t.Cleanup(func() { setCategories(nil) })
+
+	for _, tt := range []struct {
+		category string
+		status   int
+	}{
+		{"ShareVisible", http.StatusFound},
+		{"ShareHidden", http.StatusBadRequest},
+		{"ShareMissing", http.StatusBadRequest},
+	} {
+		form := url.Values{"category": {tt.category}, "hours": {"1"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/shares/create", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		adminCreateShareHandler(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: 返回 %d，期望 %d", tt.category, rec.Code, tt.status)
+		}
+	}
+	for _, link := range listShareLinks() {
+		if link.Category != "ShareVisible" {
+			t.Errorf("为 %s 生成了分享链接", link.Category)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+	"unicode"
+)
+
+const (
+	sortName    = "name"
+	sortNatural = "natural"
+	sortMtime   = "mtime"
+	sortExif    = "exif"
+	sortCount   = "count"
+	sortRandom  = "random"
+)
+
+type sortOption struct {
+	Value string
+	Label string
+}
+
+var categorySortOptions = []sortOption{
+	{sortName, "名称"},
+	{sortNatural, "自然顺序"},
+	{sortMtime, "更新时间"},
+	{sortExif, "拍摄时间"},
+	{sortCount, "图片数量"},
+	{sortRandom, "随机"},
+}
+
+var imageSortOptions = []sortOption{
+	{sortName, "名称"},
+	{sortNatural, "自然顺序"},
+	{sortMtime, "修改时间"},
+	{sortExif, "拍摄时间"},
+	{sortRandom, "随机"},
+}
+
+// 当前请求使用的排序方式
+type sortSpec struct {
+	By    string
+	Order string
+	Seed  uint64 // 随机排序的种子，分页时需保持不变
+}
+
+// 在接口响应中返回实际使用的排序方式，随机排序时附带种子供翻页时传回
+func (spec sortSpec) addTo(response map[string]interface{}) {
+	response["sort"] = spec.By
+	response["order"] = spec.Order
+	if spec.By == sortRandom {
+		response["seed"] = spec.Seed
+	}
+}
+
+func validSort(options []sortOption, value string) bool {
+	return slices.ContainsFunc(options, func(o sortOption) bool { return o.Value == value })
+}
+
+// 时间与数量默认从大到小，其余默认从小到大
+func defaultOrder(by string) string {
+	switch by {
+	case sortMtime, sortExif, sortCount:
+		return "desc"
+	}
+	return "asc"
+}
+
+// 解析排序方式：请求参数 sort、order 优先，其次依次使用 defaults 中的配置（分类配置、站点配置）
+func parseSort(r *http.Request, options []sortOption, defaults ...[2]string) sortSpec {
+	query := r.URL.Query()
+	spec := sortSpec{By: query.Get("sort"), Order: query.Get("order")}
+	if !validSort(options, spec.By) {
+		spec.By = ""
+		for _, d := range defaults {
+			if validSort(options, d[0]) {
+				spec.By = d[0]
+				if spec.Order == "" {
+					spec.Order = d[1]
+				}
+				break
+			}
+		}
+	}
+	if spec.By == "" {
+		spec.By = sortName
+	}
+	if spec.Order != "asc" && spec.Order != "desc" {
+		spec.Order = defaultOrder(spec.By)
+	}
+	if spec.By == sortRandom {
+		seed, err := strconv.ParseUint(query.Get("seed"), 10, 53)
+		if err != nil || seed == 0 {
+			// 限制在 53 位以内，便于前端脚本原样传回
+			seed = rand.Uint64()>>11 | 1
+		}
+		spec.Seed = seed
+	}
+	return spec
+}
+
+// 分类排序方式，站点配置为 category_sort、category_order
+func parseCategorySort(r *http.Request, meta AlbumMeta) sortSpec {
+	return parseSort(r, categorySortOptions,
+		[2]string{meta.CategorySort, meta.CategoryOrder},
+		[2]string{config.CategorySort, config.CategoryOrder})
+}
+
+// 图片排序方式，站点配置为 image_sort、image_order
+func parseImageSort(r *http.Request, meta AlbumMeta) sortSpec {
+	return parseSort(r, imageSortOptions,
+		[2]string{meta.ImageSort, meta.ImageOrder},
+		[2]string{config.ImageSort, config.ImageOrder})
+}
+
+// 随机排序的结果只取决于种子，同一种子在各分页间顺序一致
+func shuffle[T any](list []T, seed uint64) {
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	rng.Shuffle(len(list), func(i, j int) {
+		list[i], list[j] = list[j], list[i]
+	})
+}
+
+// 自然顺序比较，数字部分按数值大小比较，如 img2 排在 img10 之前
+func naturalCompare(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			si, sj := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			na := trimLeadingZeros(ra[si:i])
+			nb := trimLeadingZeros(rb[sj:j])
+			if c := cmp.Compare(len(na), len(nb)); c != 0 {
+				return c
+			}
+			if c := slices.Compare(na, nb); c != 0 {
+				return c
+			}
+			continue
+		}
+		la, lb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if la != lb {
+			return cmp.Compare(la, lb)
+		}
+		i++
+		j++
+	}
+	if c := cmp.Compare(len(ra)-i, len(rb)-j); c != 0 {
+		return c
+	}
+	return cmp.Compare(a, b)
+}
+
+func trimLeadingZeros(digits []rune) []rune {
+	for len(digits) > 1 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+	return digits
+}
+
+// 缓存中的默认顺序：优先使用 album.yaml 中的 sort 键，未设置时使用目录名
+func sortCategories(categories []Category) {
+	slices.SortStableFunc(categories, func(a, b Category) int {
+		return cmp.Compare(a.sortKey(), b.sortKey())
+	})
+}
+
+func (c Category) sortKey() string {
+	if c.SortKey != "" {
+		return c.SortKey
+	}
+	return c.Name
+}
+
+// 分类的拍摄时间取封面图片的拍摄时间，没有时使用更新时间
+func (c Category) captureTime() time.Time {
+	coverPath := path.Join(c.Path, c.CoverImage)
+	_, modTime, err := statMedia(coverPath)
+	if err != nil {
+		return c.modTime
+	}
+	if taken, ok := imageCaptureTime(coverPath, modTime); ok {
+		return taken
+	}
+	return c.modTime
+}
+
+// 返回排序后的分类列表副本，不修改传入的切片
+func sortCategoryList(categories []Category, spec sortSpec) []Category {
+	list := slices.Clone(categories)
+	if spec.By == sortRandom {
+		shuffle(list, spec.Seed)
+		return list
+	}
+	var taken map[string]time.Time
+	if spec.By == sortExif {
+		taken = make(map[string]time.Time, len(list))
+		for _, c := range list {
+			taken[c.Path] = c.captureTime()
+		}
+	}
+	slices.SortStableFunc(list, func(a, b Category) int {
+		var result int
+		switch spec.By {
+		case sortNatural:
+			result = naturalCompare(a.Title, b.Title)
+		case sortMtime:
+			result = a.modTime.Compare(b.modTime)
+		case sortExif:
+			result = taken[a.Path].Compare(taken[b.Path])
+		case sortCount:
+			result = cmp.Compare(a.ImageCount, b.ImageCount)
+		default:
+			result = cmp.Compare(a.sortKey(), b.sortKey())
+		}
+		if result == 0 {
+			result = cmp.Compare(a.Name, b.Name)
+		}
+		if spec.Order == "desc" {
+			return -result
+		}
+		return result
+	})
+	return list
+}
+
+// 按指定方式排序图片，dirPath 为图片所在目录
+func sortImages(images []Image, dirPath string, spec sortSpec) {
+	if spec.By == sortRandom {
+		shuffle(images, spec.Seed)
+		return
+	}
+	if spec.By == sortExif {
+		for i := range images {
+			taken, ok := imageCaptureTime(path.Join(dirPath, images[i].Name), images[i].ModTime)
+			if !ok {
+				taken = images[i].ModTime
+			}
+			images[i].taken = taken
+		}
+	}
+	slices.SortStableFunc(images, func(a, b Image) int {
+		var result int
+		switch spec.By {
+		case sortNatural:
+			result = naturalCompare(a.Name, b.Name)
+		case sortMtime:
+			result = a.ModTime.Compare(b.ModTime)
+		case sortExif:
+			result = a.taken.Compare(b.taken)
+		default:
+			result = cmp.Compare(a.Name, b.Name)
+		}
+		if result == 0 {
+			result = cmp.Compare(a.Name, b.Name)
+		}
+		if spec.Order == "desc" {
+			return -result
+		}
+		return result
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	names := []string{"img10.jpg", "img2.jpg", "IMG5.jpg", "img1.jpg"}
+	slices.SortFunc(names, naturalCompare)
+	want := []string{"img1.jpg", "img2.jpg", "IMG5.jpg", "img10.jpg"}
+	if !slices.Equal(names, want) {
+		t.Errorf("自然顺序为 %v，期望 %v", names, want)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	for _, tt := range []struct {
+		query    string
+		defaults [2]string
+		by       string
+		order    string
+	}{
+		{"", [2]string{}, sortName, "asc"},
+		{"?sort=mtime", [2]string{}, sortMtime, "desc"},
+		{"?sort=mtime&order=asc", [2]string{}, sortMtime, "asc"},
+		{"?sort=bogus", [2]string{sortNatural, "desc"}, sortNatural, "desc"},
+		{"?order=sideways", [2]string{}, sortName, "asc"},
+		{"?sort=count", [2]string{}, sortName, "asc"}, // 图片不支持按数量排序
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+		spec := parseSort(r, imageSortOptions, tt.defaults)
+		if spec.By != tt.by || spec.Order != tt.order {
+			t.Errorf("%q: %s %s，期望 %s %s", tt.query, spec.By, spec.Order, tt.by, tt.order)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/?sort=random&seed=42", nil)
+	if spec := parseSort(r, imageSortOptions); spec.Seed != 42 {
+		t.Errorf("随机排序未沿用请求中的种子: %d", spec.Seed)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/?sort=random", nil)
+	if spec := parseSort(r, imageSortOptions); spec.Seed == 0 || spec.Seed >= 1<<53 {
+		t.Errorf("生成的种子 %d 无效", spec.Seed)
+	}
+}
+
+func TestSortImages(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	images := []Image{
+		{Name: "b10.jpg", ModTime: base},
+		{Name: "b2.jpg", ModTime: base.Add(2 * time.Hour)},
+		{Name: "a.jpg", ModTime: base.Add(time.Hour)},
+	}
+	names := func() []string {
+		var list []string
+		for _, image := range images {
+			list = append(list, image.Name)
+		}
+		return list
+	}
+	for _, tt := range []struct {
+		spec sortSpec
+		want []string
+	}{
+		{sortSpec{By: sortName, Order: "asc"}, []string{"a.jpg", "b10.jpg", "b2.jpg"}},
+		{sortSpec{By: sortNatural, Order: "asc"}, []string{"a.jpg", "b2.jpg", "b10.jpg"}},
+		{sortSpec{By: sortMtime, Order: "desc"}, []string{"b2.jpg", "a.jpg", "b10.jpg"}},
+	} {
+		sortImages(images, "Sort", tt.spec)
+		if got := names(); !slices.Equal(got, tt.want) {
+			t.Errorf("%s %s: %v，期望 %v", tt.spec.By, tt.spec.Order, got, tt.want)
+		}
+	}
+
+	// 目录按相同顺序读取时，同一种子的随机顺序保持不变，翻页时不会重复或遗漏
+	sortImages(images, "Sort", sortSpec{By: sortName, Order: "asc"})
+	sortImages(images, "Sort", sortSpec{By: sortRandom, Seed: 7})
+	first := names()
+	sortImages(images, "Sort", sortSpec{By: sortName, Order: "asc"})
+	sortImages(images, "Sort", sortSpec{By: sortRandom, Seed: 7})
+	if got := names(); !slices.Equal(got, first) {
+		t.Errorf("同一种子的随机顺序不一致: %v %v", first, got)
+	}
+}
+
+func TestSortCategoryList(t *testing.T) {
+	categories := []Category{
+		{Name: "a", Title: "a", ImageCount: 5},
+		{Name: "b", Title: "b", ImageCount: 20, SortKey: "0"},
+		{Name: "c", Title: "c", ImageCount: 10},
+	}
+	sorted := sortCategoryList(categories, sortSpec{By: sortCount, Order: "desc"})
+	if sorted[0].Name != "b" || sorted[1].Name != "c" || sorted[2].Name != "a" {
+		t.Errorf("按数量排序为 %v", sorted)
+	}
+	// 默认顺序优先使用 album.yaml 中的 sort 键
+	sorted = sortCategoryList(categories, sortSpec{By: sortName, Order: "asc"})
+	if sorted[0].Name != "b" {
+		t.Errorf("按名称排序时未使用 sort 键: %v", sorted)
+	}
+	if categories[0].Name != "a" || categories[1].Name != "b" {
+		t.Error("排序不应修改传入的切片")
+	}
+}
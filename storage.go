@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// 图片存储，名称为相对图片目录、以 / 分隔的路径，根目录为 "."
+type Storage interface {
+	fs.ReadDirFS
+	fs.StatFS
+}
+
+// 存储中打开的文件，需要支持随机读取以便读取归档目录、EXIF 以及响应 Range 请求
+type StorageFile interface {
+	fs.File
+	io.ReaderAt
+	io.Seeker
+}
+
+var imageStore Storage
+
+var errStorageSeek = errors.New("存储不支持随机读取")
+
+// 按配置初始化图片存储，storage 为 local（默认）时使用 image_dir 目录，为 s3 时使用对象存储
+func initStorage() {
+	switch config.Storage {
+	case "", "local":
+		imageStore = os.DirFS(config.ImageDir).(Storage)
+	case "s3":
+		s3, err := newS3Storage(config.S3)
+		if err != nil {
+			log.Fatalf("无法初始化对象存储: %v", err)
+		}
+		imageStore = s3
+	default:
+		log.Fatalf("未知的存储类型 %s，可选 local、s3", config.Storage)
+	}
+}
+
+// 是否使用本地目录，文件变更通知只支持本地目录
+func localStorage() bool {
+	return config.Storage == "" || config.Storage == "local"
+}
+
+// 打开存储中的文件
+func openStorageFile(name string) (StorageFile, error) {
+	file, err := imageStore.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	storageFile, ok := file.(StorageFile)
+	if !ok {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errStorageSeek}
+	}
+	return storageFile, nil
+}
+
+// 原图文件服务，只提供媒体等普通文件：目录列表会暴露隐藏的子相册，以点开头的文件与相册配置也不对外提供
+func imageFileServer() http.Handler {
+	return http.FileServer(http.FS(originalsFS{imageStore}))
+}
+
+type originalsFS struct {
+	fs.FS
+}
+
+func (f originalsFS) Open(name string) (fs.File, error) {
+	if path.Base(name) == albumFileName || slices.ContainsFunc(strings.Split(name, "/"), hiddenName) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err != nil || info.IsDir() {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return file, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	defaultThumbWidth = 480
+	thumbJPEGQuality  = 82
+	// 生成方式变化时递增，使旧缓存失效
+	thumbVersion = 2
+	// 超过该像素数的图片解码占用内存过大，直接返回原图；解码为 RGBA 时每像素 4 字节，约 200MB
+	thumbMaxPixels = 50_000_000
+	// 同时解码的像素总数上限，与生成并发数无关，总内存占用约 400MB
+	thumbDecodeBudget = 100_000_000
+)
+
+// 可生成的缩略图宽度，请求的宽度向上取整到其中一档，避免缓存无限增长
+var thumbWidths = []int{160, 320, 480, 640, 960, 1280}
+
+// 可以解码并缩放的格式，其余格式（如 svg、ico）直接返回原图
+var thumbFormats = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// 视频与音频的缩略图
+var mediaPlaceholders = map[string]string{
+	mediaVideo: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 480 360"><rect width="480" height="360" fill="#343a40"/><circle cx="240" cy="180" r="64" fill="#fff" fill-opacity="0.85"/><path d="M220 145v70l60-35z" fill="#343a40"/></svg>`,
+	mediaAudio: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 480 360"><rect width="480" height="360" fill="#495057"/><path d="M210 120v105a30 30 0 1 0 20 28V160l70-15v60a30 30 0 1 0 20 28V100z" fill="#fff" fill-opacity="0.85"/></svg>`,
+}
+
+// 原图不需要缩小或无法缩小，直接返回原图
+var errThumbSkip = errors.New("无需生成缩略图")
+
+type thumbCall struct {
+	wg   sync.WaitGroup
+	path string
+	err  error
+}
+
+var (
+	// 限制同时生成缩略图的数量
+	thumbSlots chan struct{}
+	// 正在解码的像素总数，超出预算时等待其他图片解码完成
+	thumbDecodePixels int
+	thumbDecodeCond   = sync.NewCond(&sync.Mutex{})
+	// 同一缩略图同时只生成一次，其余请求等待结果
+	thumbInflight   = make(map[string]*thumbCall)
+	thumbInflightMu sync.Mutex
+)
+
+// 初始化缩略图缓存目录与生成并发数
+func initThumbnails() {
+	workers := config.ThumbWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	thumbSlots = make(chan struct{}, workers)
+	if err := os.MkdirAll(thumbCacheDir(), 0755); err != nil {
+		log.Fatalf("无法创建缓存目录 %s: %v", thumbCacheDir(), err)
+	}
+}
+
+// 缓存目录，默认 ./cache
+func cacheDir() string {
+	if config.CacheDir != "" {
+		return config.CacheDir
+	}
+	return "cache"
+}
+
+func thumbCacheDir() string {
+	return filepath.Join(cacheDir(), "thumbs")
+}
+
+// 将请求的宽度取整到可用档位
+func thumbWidth(value string) int {
+	width, err := strconv.Atoi(value)
+	if err != nil || width <= 0 {
+		return defaultThumbWidth
+	}
+	for _, w := range thumbWidths {
+		if width <= w {
+			return w
+		}
+	}
+	return thumbWidths[len(thumbWidths)-1]
+}
+
+// 缩略图处理器，路径与 /images/ 相同，通过 w 参数指定宽度
+func thumbHandler(w http.ResponseWriter, r *http.Request) {
+	rel, ok := resolveCategoryPath(r.URL.Path[len("/thumbs/"):])
+	if !ok {
+		http.Error(w, "无效路径", http.StatusBadRequest)
+		return
+	}
+	ext := strings.ToLower(path.Ext(rel))
+	kind := mediaKind(rel)
+	if kind == "" || !categoryAllowed(currentUser(r), path.Dir(rel)) {
+		http.NotFound(w, r)
+		return
+	}
+	size, modTime, err := statMedia(rel)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if kind != mediaImage {
+		// 视频与音频无法解码出画面，使用占位图
+		w.Header().Set("Content-Type", "image/svg+xml")
+		io.WriteString(w, mediaPlaceholders[kind])
+		return
+	}
+	if !thumbFormats[ext] {
+		serveMedia(w, r, rel)
+		return
+	}
+	thumbPath, err := thumbnail(rel, size, modTime, thumbWidth(r.URL.Query().Get("w")))
+	if err != nil {
+		if !errors.Is(err, errThumbSkip) {
+			log.Printf("无法生成缩略图 %s: %v", rel, err)
+		}
+		serveMedia(w, r, rel)
+		return
+	}
+	http.ServeFile(w, r, thumbPath)
+}
+
+// 返回缩略图缓存路径，缓存按原图路径、大小、修改时间与宽度区分，原图变化后自动重新生成
+func thumbnail(rel string, size int64, modTime time.Time, width int) (string, error) {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s\x00%d\x00%d\x00%d", thumbVersion, rel, size, modTime.UnixNano(), width)))
+	key := hex.EncodeToString(sum[:])
+	ext := ".jpg"
+	switch strings.ToLower(path.Ext(rel)) {
+	case ".png", ".gif":
+		// 保留透明通道
+		ext = ".png"
+	}
+	thumbPath := filepath.Join(thumbCacheDir(), key[:2], key+ext)
+	if _, err := os.Stat(thumbPath); err == nil {
+		return thumbPath, nil
+	}
+
+	thumbInflightMu.Lock()
+	if call, ok := thumbInflight[key]; ok {
+		thumbInflightMu.Unlock()
+		call.wg.Wait()
+		return call.path, call.err
+	}
+	call := &thumbCall{path: thumbPath}
+	call.wg.Add(1)
+	thumbInflight[key] = call
+	thumbInflightMu.Unlock()
+
+	thumbSlots <- struct{}{}
+	call.err = generateThumbnail(rel, thumbPath, width)
+	<-thumbSlots
+
+	call.wg.Done()
+	thumbInflightMu.Lock()
+	delete(thumbInflight, key)
+	thumbInflightMu.Unlock()
+	return call.path, call.err
+}
+
+// 缩放图片并写入缓存，先写临时文件再重命名，避免读取到写了一半的文件
+func generateThumbnail(filePath, thumbPath string, width int) error {
+	file, err := openMedia(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return err
+	}
+	// 缩略图不保留 EXIF，需要按拍摄方向旋转，浏览器才能正确显示
+	orientation := 1
+	if data, err := readExif(filePath); err == nil && data.Orientation != 0 {
+		orientation = data.Orientation
+	}
+	displayWidth := cfg.Width
+	if orientation >= 5 {
+		displayWidth = cfg.Height
+	}
+	if displayWidth <= width || cfg.Width*cfg.Height > thumbMaxPixels {
+		return errThumbSkip
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	pixels := cfg.Width * cfg.Height
+	acquireDecodePixels(pixels)
+	defer releaseDecodePixels(pixels)
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return err
+	}
+
+	bounds := src.Bounds()
+	scaledW, scaledH := width, max(1, bounds.Dy()*width/bounds.Dx())
+	if orientation >= 5 {
+		// 旋转90度后宽高互换，按显示宽度计算缩放尺寸
+		scaledW, scaledH = max(1, bounds.Dx()*width/bounds.Dy()), width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, scaledW, scaledH))
+	if filepath.Ext(thumbPath) != ".png" {
+		// JPEG 不支持透明，透明区域使用白色背景
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	dst = applyOrientation(dst, orientation)
+
+	if err := os.MkdirAll(filepath.Dir(thumbPath), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(thumbPath), ".thumb-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if filepath.Ext(thumbPath) == ".png" {
+		err = png.Encode(tmp, dst)
+	} else {
+		err = jpeg.Encode(tmp, dst, &jpeg.Options{Quality: thumbJPEGQuality})
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), thumbPath)
+}
+
+// 占用解码预算，单张图片不超过 thumbMaxPixels，没有其他图片在解码时总能立即开始
+func acquireDecodePixels(pixels int) {
+	thumbDecodeCond.L.Lock()
+	for thumbDecodePixels > 0 && thumbDecodePixels+pixels > thumbDecodeBudget {
+		thumbDecodeCond.Wait()
+	}
+	thumbDecodePixels += pixels
+	thumbDecodeCond.L.Unlock()
+}
+
+func releaseDecodePixels(pixels int) {
+	thumbDecodeCond.L.Lock()
+	thumbDecodePixels -= pixels
+	thumbDecodeCond.L.Unlock()
+	thumbDecodeCond.Broadcast()
+}
+
+// 按 EXIF 方向变换图片，返回正向显示的图片
+func applyOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转180度
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90度
+				dx, dy = h-1-y, x
+			case 7: // 沿副对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转90度
+				dx, dy = y, w-1-x
+			}
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 生成指定尺寸的 PNG，声明的尺寸可以与实际像素不符，用于测试只读取文件头的情况
+func testPNG(t *testing.T, width, height, declaredWidth, declaredHeight int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	content := buf.Bytes()
+	// IHDR 数据块紧跟在 8 字节签名之后，宽高位于第 16 至 24 字节，随后是覆盖类型与数据的 CRC
+	binary.BigEndian.PutUint32(content[16:], uint32(declaredWidth))
+	binary.BigEndian.PutUint32(content[20:], uint32(declaredHeight))
+	binary.BigEndian.PutUint32(content[29:], crc32.ChecksumIEEE(content[12:29]))
+	return content
+}
+
+func TestGenerateThumbnail(t *testing.T) {
+	writeImageFile(t, "Thumbs/wide.png", testPNG(t, 1000, 10, 1000, 10))
+	writeImageFile(t, "Thumbs/huge.png", testPNG(t, 1, 1, 10000, 10000))
+	dir := t.TempDir()
+
+	thumbPath := filepath.Join(dir, "wide.png")
+	if err := generateThumbnail("Thumbs/wide.png", thumbPath, 160); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(thumbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	cfg, err := png.DecodeConfig(file)
+	if err != nil || cfg.Width != 160 || cfg.Height != 1 {
+		t.Errorf("缩略图尺寸为 %dx%d: %v", cfg.Width, cfg.Height, err)
+	}
+
+	// 超过像素上限的图片不解码
+	if err := generateThumbnail("Thumbs/huge.png", filepath.Join(dir, "huge.png"), 160); !errors.Is(err, errThumbSkip) {
+		t.Errorf("超大图片返回 %v，期望 errThumbSkip", err)
+	}
+}
+
+// 同时解码的像素总数超出预算时等待
+func TestDecodeBudget(t *testing.T) {
+	acquireDecodePixels(thumbDecodeBudget - 10)
+	acquired := make(chan struct{})
+	go func() {
+		acquireDecodePixels(20)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("超出解码预算时应等待")
+	case <-time.After(50 * time.Millisecond):
+	}
+	releaseDecodePixels(thumbDecodeBudget - 10)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("释放预算后仍在等待")
+	}
+	releaseDecodePixels(20)
+
+	// 单张图片超过剩余预算但没有其他图片在解码时立即开始
+	acquireDecodePixels(thumbDecodeBudget)
+	releaseDecodePixels(thumbDecodeBudget)
+}
@@ -31,12 +31,31 @@ const indexDynamicTemplate = `<!DOCTYPE html>
 <body>
     <div class="container">
         <h1 class="my-4 text-center">{{.Config.Title}}</h1>
+        {{if ne .UserInfo.Provider ""}}<div class="text-center text-muted mb-3">{{if ne .UserInfo.Username ""}}当前用户：{{.UserInfo.Username}} · {{end}}{{if eq .UserInfo.Role "admin"}}<a href="/admin/">管理</a> · {{end}}<a href="/account/tokens">访问令牌</a> · {{if eq .UserInfo.Provider "user"}}<a href="/account/2fa">两步验证</a> · {{end}}<form method="POST" action="/logout" class="d-inline">{{csrfField}}<button type="submit" class="btn btn-link p-0 border-0 align-baseline">退出登录</button></form></div>{{end}}
+        <div class="d-flex flex-wrap justify-content-between gap-2 mb-3">
+            <form method="GET" action="/" class="d-flex gap-2" role="search">
+                <input type="search" name="q" value="{{.Query}}" maxlength="100" class="form-control form-control-sm" placeholder="搜索分类、标签或文件名">
+                <button type="submit" class="btn btn-sm btn-outline-primary text-nowrap">搜索</button>
+            </form>
+            {{if not .Query}}
+            <form method="GET" class="d-flex gap-2">
+                <select name="sort" class="form-select form-select-sm w-auto" onchange="this.form.submit()">
+                    {{range .SortOptions}}<option value="{{.Value}}"{{if eq .Value $.Sort.By}} selected{{end}}>{{.Label}}</option>{{end}}
+                </select>
+                <select name="order" class="form-select form-select-sm w-auto" onchange="this.form.submit()">
+                    <option value="asc"{{if eq .Sort.Order "asc"}} selected{{end}}>升序</option>
+                    <option value="desc"{{if eq .Sort.Order "desc"}} selected{{end}}>降序</option>
+                </select>
+            </form>
+            {{end}}
+        </div>
+        {{if .Query}}<p class="text-muted">“{{.Query}}”的搜索结果<span id="search-total"></span> · <a href="/">返回全部分类</a></p>{{end}}
         <div class="row" id="category-container">
         </div>
         <div id="loading">加载中...</div>
     </div>
 
-    {{if ne .Config.LinuxdoEnable "false"}}
+    {{if ne .UserInfo.ProviderName ""}}
     <div class="modal fade" id="exampleModal" tabindex="-1" aria-labelledby="exampleModalLabel" aria-hidden="true">
     <div class="modal-dialog">
         <div class="modal-content">
@@ -44,7 +63,7 @@ const indexDynamicTemplate = `<!DOCTYPE html>
         <div class="text-center">
         <img src="data:image/gif;base64,R0lGODlhAQABAAAAACH5BAEKAAEALAAAAAABAAEAAAICTAEAOw==" id="avatar" alt="Logo" style="width: 100px; height: 100px; border-radius: 50%;"><br>
         </div>
-            <p class="text-center">欢迎来自Linux.do的佬友：<span id="username" style="color: #FF9800;">XXX</span></p>
+            <p class="text-center">{{if eq .UserInfo.Provider "linuxdo"}}欢迎来自Linux.do的佬友{{else}}欢迎来自{{.UserInfo.ProviderName}}的用户{{end}}：<span id="username" style="color: #FF9800;">XXX</span></p>
         </div>
         <div class="modal-footer">
             <button type="button" class="btn btn-secondary" data-bs-dismiss="modal">Close</button>
@@ -61,6 +80,14 @@ const indexDynamicTemplate = `<!DOCTYPE html>
         const limit = 20;
         let loading = false;
         let hasMore = true;
+        const searchParams = new URLSearchParams(window.location.search);
+        const query = (searchParams.get('q') || '').trim();
+        let sortQuery = '';
+        ['sort', 'order', 'seed'].forEach(key => {
+            if (searchParams.get(key)) {
+                sortQuery += '&' + key + '=' + encodeURIComponent(searchParams.get(key));
+            }
+        });
 		
 		const lazyImageObserver = new IntersectionObserver((entries, observer) => {
             entries.forEach(entry => {
@@ -82,31 +109,73 @@ const indexDynamicTemplate = `<!DOCTYPE html>
         });
 
 
+        function escapeHtml(text) {
+            return String(text).replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'})[c]);
+        }
+        // 逐段转义相对路径，用于拼接链接地址
+        function encodePath(path) {
+            return String(path).split('/').map(encodeURIComponent).join('/');
+        }
+
+        // 搜索结果卡片，分类链接到分类页，图片在新窗口打开原图
+        function searchResultHtml(item) {
+            const placeholder = 'data:image/gif;base64,R0lGODlhAQABAAAAACH5BAEKAAEALAAAAAABAAEAAAICTAEAOw==';
+            if (item.Type === 'category') {
+                return '<div class="col-md-3 col-sm-6">' +
+                    '<div class="category-card">' +
+                        '<a href="/category/' + item.EncodedCategory + '" style="text-decoration: none;">' +
+                            '<img data-src="/thumbs/' + item.EncodedCategory + '/' + encodePath(item.CoverImage) + '?w=480" src="' + placeholder +
+                            '" class="img-fluid lazy" alt="' + escapeHtml(item.Title) + '">' +
+                            '<p>' + escapeHtml(item.Title) + '<br><small class="text-muted">' + escapeHtml(item.Category) + '</small></p>' +
+                        '</a>' +
+                    '</div>' +
+                '</div>';
+            }
+            return '<div class="col-md-3 col-sm-6">' +
+                '<div class="category-card">' +
+                    '<a href="/images/' + item.EncodedCategory + '/' + item.EncodedName + '" target="_blank" style="text-decoration: none;">' +
+                        '<img data-src="/thumbs/' + item.EncodedCategory + '/' + item.EncodedName + '?w=480" src="' + placeholder +
+                        '" class="img-fluid lazy" alt="' + escapeHtml(item.Name) + '">' +
+                    '</a>' +
+                    '<p>' + escapeHtml(item.Name) + '<br><a href="/category/' + item.EncodedCategory + '" class="small text-muted">' +
+                        escapeHtml(item.CategoryTitle) + '</a></p>' +
+                '</div>' +
+            '</div>';
+        }
+
         function loadCategories() {
             if (loading || !hasMore) return;
             loading = true;
             $('#loading').show();
 
             $.ajax({
-                url: '/api/index?page='+page+'&limit='+limit,
+                url: query ? '/api/search?q=' + encodeURIComponent(query) + '&page=' + page + '&limit=' + limit
+                    : '/api/index?page='+page+'&limit='+limit+sortQuery,
                 method: 'GET',
                 success: function(data) {
-                    const categories = data.categories;
+                    if (data.seed && sortQuery.indexOf('seed=') < 0) {
+                        // 随机排序时沿用首次返回的种子，保证翻页顺序一致
+                        sortQuery += '&seed=' + data.seed;
+                    }
+                    if (query) {
+                        $('#search-total').text('，共 ' + data.total + ' 个');
+                    }
+                    const categories = query ? data.results : data.categories;
                     if (categories.length === 0) {
                         hasMore = false;
-                        $('#loading').text('没有更多分类');
+                        $('#loading').text(query ? (page === 1 ? '没有找到匹配的结果' : '没有更多结果') : '没有更多分类');
                         return;
                     }
 
                     categories.forEach(category => {
-                        const html = 
+                        const html = query ? searchResultHtml(category) :
                             '<div class="col-md-3 col-sm-6">' +
                                 '<div class="category-card">' +
                                     '<a href="/category/' + category.EncodedName + '" style="text-decoration: none;">' +
-                                        '<img data-src="/images/' + category.EncodedName + '/' + category.CoverImage 
+                                        '<img data-src="/thumbs/' + category.EncodedName + '/' + encodePath(category.CoverImage) + '?w=480'
 										+ '" src="data:image/gif;base64,R0lGODlhAQABAAAAACH5BAEKAAEALAAAAAABAAEAAAICTAEAOw==" class="img-fluid lazy" alt="' +
-										 category.Name + '">' +
-                                        '<p>' + category.Name + '</p>' +
+										 escapeHtml(category.Title) + '">' +
+                                        '<p>' + escapeHtml(category.Title) + '</p>' +
                                     '</a>' +
                                 '</div>' +
                             '</div>';
@@ -149,17 +218,17 @@ const indexDynamicTemplate = `<!DOCTYPE html>
                 }
             });
             
-            {{if ne .Config.LinuxdoEnable "false"}}
+            {{if ne .UserInfo.ProviderName ""}}
             if (!checkCookie("modalClosed")) {
                 $('#username').text("{{.UserInfo.Username}}");
                 $('#avatar').attr("src", "{{.UserInfo.AvatarURL}}");
-                {{if ne .UserInfo.Username ""}}$('#exampleModal').modal('show');{{end}}
+                $('#exampleModal').modal('show');
             }
             {{end}}
 
         });
 
-        {{if ne .Config.LinuxdoEnable "false"}}
+        {{if ne .UserInfo.ProviderName ""}}
         $('#exampleModal').on('hidden.bs.modal', function () {
             if (!checkCookie("modalClosed")) {
                 document.cookie = "modalClosed=true; path=/; max-age=" + 60 * 60 * 24; // 1天有效期
@@ -176,15 +245,22 @@ const categoryDynamicTemplate = `<!DOCTYPE html>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <meta name="description" content="{{.Category}} 的图片集合， {{.Config.Title}}">
+    <meta name="description" content="{{.Title}} 的图片集合， {{.Config.Title}}">
     <meta name="keywords" content="{{.Category}}, 图片, 相册">
-    <title>{{.Category}} - {{.Config.Title}} - 图片合集</title>
+    <title>{{.Title}} - {{.Config.Title}} - 图片合集</title>
     <link rel="stylesheet" href="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/css/bootstrap.min.css">
     <link rel="stylesheet" href="https://jsd.051214.xyz/npm/@fancyapps/fancybox@3.5.7/dist/jquery.fancybox.min.css">
     <link rel="shortcut icon" type="image/x-icon" href="{{.Config.Icon}}" />
     <style>
-        .image-card { margin-bottom: 20px; }
+        .image-card { position: relative; margin-bottom: 20px; }
+        .category-card { text-align: center; margin-bottom: 20px; }
+        .category-card img { width: 100%; height: auto; border-radius: 8px; }
+        .category-card p { margin-top: 10px; font-size: 1.1em; }
         .image-card img { width: 100%; height: auto; border-radius: 8px; }
+        .select-box { display: none; position: absolute; top: 8px; left: 8px; z-index: 10; width: 1.4em; height: 1.4em; }
+        .select-action { display: none; }
+        body.selecting .select-box { display: block; }
+        body.selecting .select-action { display: inline-block; }
         #back-buttons {position: fixed;bottom: 20px;right: 20px;display: flex;flex-direction: column;gap: 10px;z-index: 1000;}
         #back-buttons button {padding: 5px 10px;border: none;color: white;border-radius: 5px;cursor: pointer;font-size: 14px;transition: all 0.3s;}
         #back-buttons button:hover {background-color: #bdc5ca;}
@@ -203,12 +279,38 @@ const categoryDynamicTemplate = `<!DOCTYPE html>
 </head>
 <body>
     <div class="container">
-        <h1 class="my-4 text-center">{{.Category}}</h1>
+        <h1 class="my-4 text-center">{{.Title}}</h1>
+        {{if .Description}}<p class="text-center text-muted">{{.Description}}</p>{{end}}
+        {{if .Tags}}<p class="text-center">{{range .Tags}}<span class="badge bg-secondary me-1">{{.}}</span>{{end}}</p>{{end}}
+        <nav aria-label="breadcrumb">
+            <ol class="breadcrumb">
+                <li class="breadcrumb-item"><a href="/">首页</a></li>
+                {{range .Breadcrumbs}}{{if eq .EncodedName $.EncodedName}}<li class="breadcrumb-item active" aria-current="page">{{.Name}}</li>{{else}}<li class="breadcrumb-item"><a href="/category/{{.EncodedName}}">{{.Name}}</a></li>{{end}}
+                {{end}}
+            </ol>
+        </nav>
+        <form method="GET" class="d-flex justify-content-end gap-2 mb-3">
+            <select name="sort" class="form-select form-select-sm w-auto" onchange="this.form.submit()">
+                {{range .SortOptions}}<option value="{{.Value}}"{{if eq .Value $.Sort.By}} selected{{end}}>{{.Label}}</option>{{end}}
+            </select>
+            <select name="order" class="form-select form-select-sm w-auto" onchange="this.form.submit()">
+                <option value="asc"{{if eq .Sort.Order "asc"}} selected{{end}}>升序</option>
+                <option value="desc"{{if eq .Sort.Order "desc"}} selected{{end}}>降序</option>
+            </select>
+            {{if .Images}}
+            <a href="/download/{{.EncodedName}}.zip" class="btn btn-sm btn-outline-secondary">下载全部</a>
+            <button type="button" class="btn btn-sm btn-outline-secondary" onclick="toggleSelect()">选择下载</button>
+            <button type="button" class="btn btn-sm btn-primary select-action" onclick="downloadSelected()">下载所选</button>
+            {{end}}
+        </form>
+        <form id="download-form" method="POST" action="/download/{{.EncodedName}}.zip" class="d-none">{{csrfField}}</form>
+        <div class="row" id="children-container"></div>
         <div class="row" id="image-container">
             <!-- 图片将动态加载到这里 -->
         </div>
         <div id="loading">加载中...</div>
     </div>
+    <div id="exif-panel" style="display: none; position: fixed; top: 60px; left: 20px; z-index: 100000; max-width: 320px; padding: 12px 16px; border-radius: 8px; background: rgba(0, 0, 0, 0.75); color: #fff; font-size: 14px;"></div>
     <div id="back-buttons">
         <button id="back-btn" onclick="history.back()">⬅</button>
         <button id="top-btn" onclick="scrollToTop()">🔝</button>
@@ -218,10 +320,66 @@ const categoryDynamicTemplate = `<!DOCTYPE html>
     <script src="https://jsd.051214.xyz/npm/@fancyapps/fancybox@3.5.7/dist/jquery.fancybox.min.js"></script>
     <script src="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/js/bootstrap.bundle.min.js"></script>
 	<script>
+        // 查看大图时的图片信息面板
+        $.fancybox.defaults.btnTpl.info = '<button data-fancybox-info class="fancybox-button" title="图片信息">ℹ</button>';
+        $.fancybox.defaults.buttons = ['info', 'zoom', 'slideShow', 'fullScreen', 'thumbs', 'close'];
+        $.fancybox.defaults.afterShow = function(instance, slide) {
+            loadImageInfo(slide.src);
+        };
+        $.fancybox.defaults.afterClose = function() {
+            $('#exif-panel').hide();
+        };
+        $(document).on('click', '[data-fancybox-info]', function() {
+            $('#exif-panel').toggle();
+        });
+
+        function loadImageInfo(src) {
+            const $panel = $('#exif-panel');
+            $panel.text('加载中...');
+            $.getJSON(src.replace(/^\/images\//, '/api/image/'), function(data) {
+                const image = data.image;
+                const exif = data.exif;
+                const rows = [['文件', image.Name]];
+                if (image.Width) rows.push(['尺寸', image.Width + ' × ' + image.Height]);
+                rows.push(['大小', (image.Size / 1024 / 1024).toFixed(2) + ' MB']);
+                if (exif.capture_time) rows.push(['拍摄时间', new Date(exif.capture_time).toLocaleString()]);
+                const camera = [exif.make, exif.model].filter(Boolean).join(' ');
+                if (camera) rows.push(['相机', camera]);
+                if (exif.lens_model) rows.push(['镜头', exif.lens_model]);
+                const exposure = [
+                    exif.focal_length ? exif.focal_length + 'mm' : '',
+                    exif.f_number ? 'f/' + exif.f_number : '',
+                    exif.exposure_time ? exif.exposure_time + 's' : '',
+                    exif.iso ? 'ISO ' + exif.iso : ''
+                ].filter(Boolean).join('  ');
+                if (exposure) rows.push(['曝光', exposure]);
+                if (exif.exposure_bias) rows.push(['曝光补偿', exif.exposure_bias + ' EV']);
+                $panel.empty();
+                rows.forEach(row => $panel.append($('<div>').text(row[0] + '：' + row[1])));
+            }).fail(function() {
+                $panel.text('无法读取图片信息');
+            });
+        }
+
+        function escapeHtml(text) {
+            return String(text).replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'})[c]);
+        }
+        // 逐段转义相对路径，用于拼接链接地址
+        function encodePath(path) {
+            return String(path).split('/').map(encodeURIComponent).join('/');
+        }
+
 	    let page = 1;
         const limit = 20;
         let loading = false;
         let hasMore = true;
+        const searchParams = new URLSearchParams(window.location.search);
+        let sortQuery = '';
+        ['sort', 'order', 'seed'].forEach(key => {
+            if (searchParams.get(key)) {
+                sortQuery += '&' + key + '=' + encodeURIComponent(searchParams.get(key));
+            }
+        });
 
 		const lazyImageObserver = new IntersectionObserver((entries, observer) => {
             entries.forEach(entry => {
@@ -248,9 +406,16 @@ const categoryDynamicTemplate = `<!DOCTYPE html>
             $('#loading').show();
 
             $.ajax({
-                url: '/api/category/'+ category +'?page='+page+'&limit='+limit,
+                url: '/api/category/'+ category +'?page='+page+'&limit='+limit+sortQuery,
                 method: 'GET',
                 success: function(data) {
+                    if (data.seed && sortQuery.indexOf('seed=') < 0) {
+                        // 随机排序时沿用首次返回的种子，保证翻页顺序一致
+                        sortQuery += '&seed=' + data.seed;
+                    }
+                    if (page === 1) {
+                        renderChildren(data.children);
+                    }
                     const images = data.images;
                     if (images.length === 0) {
                         hasMore = false;
@@ -262,10 +427,13 @@ const categoryDynamicTemplate = `<!DOCTYPE html>
                         const html = 
                             '<div class="col-md-3 col-sm-6">' +
                                 '<div class="image-card">' +
-                                    '<a href="/images/' + category + '/' + image.Name + '" data-fancybox="' + category + '">' +
-                                        '<img data-src="/images/' + category + '/' + image.Name + '" alt="' + image.Name +
+                                    '<input type="checkbox" class="form-check-input select-box" value="' + escapeHtml(image.Name) + '">' +
+                                    '<a href="/images/' + category + '/' + image.EncodedName + '" data-fancybox="' + category + '"' +
+                                        (image.Kind !== 'image' ? ' data-type="video" data-video-format="' + image.Mime + '"' : '') + '>' +
+                                        '<img data-src="' + (image.Type === 'gif' ? '/images/' : '/thumbs/') + category + '/' + image.EncodedName + (image.Type === 'gif' ? '' : '?w=480') + '" alt="' + escapeHtml(image.Name) +
 										'" src="data:image/gif;base64,R0lGODlhAQABAAAAACH5BAEKAAEALAAAAAABAAEAAAICTAEAOw=="' +
-										 '" class="img-fluid lazy" ' + (image.Type === 'gif' ? 'data-type="image/gif"' : '') + '>' +
+										 '" class="img-fluid lazy" ' + (image.Type === 'gif' ? 'data-type="image/gif"' : '') +
+										 (image.Width ? ' width="' + image.Width + '" height="' + image.Height + '"' : '') + '>' +
                                     '</a>' +
                                 '</div>' +
                             '</div>';
@@ -286,13 +454,47 @@ const categoryDynamicTemplate = `<!DOCTYPE html>
                 }
             });
         }
+        function renderChildren(children) {
+            children.forEach(child => {
+                const html =
+                    '<div class="col-md-3 col-sm-6">' +
+                        '<div class="category-card">' +
+                            '<a href="/category/' + child.EncodedName + '" style="text-decoration: none;">' +
+                                '<img data-src="/thumbs/' + child.EncodedName + '/' + encodePath(child.CoverImage) + '?w=480" alt="' + escapeHtml(child.Title) + '" class="img-fluid lazy">' +
+                                '<p>' + escapeHtml(child.Title) + '</p>' +
+                            '</a>' +
+                        '</div>' +
+                    '</div>';
+                const $newItems = $(html);
+                $('#children-container').append($newItems);
+                $newItems.find('img.lazy').each(function() {
+                    lazyImageObserver.observe(this);
+                });
+            });
+        }
         function scrollToTop() {
             window.scrollTo({ top: 0, behavior: 'smooth' });
         }
+        // 勾选图片后通过表单提交打包下载
+        function toggleSelect() {
+            document.body.classList.toggle('selecting');
+        }
+        function downloadSelected() {
+            const $form = $('#download-form');
+            $form.find('input[name="name"]').remove();
+            $('.select-box:checked').each(function() {
+                $form.append($('<input type="hidden" name="name">').val(this.value));
+            });
+            if ($form.find('input[name="name"]').length === 0) {
+                alert('请先勾选要下载的文件');
+                return;
+            }
+            $form.submit();
+        }
 			
         $(document).ready(function() {
 
-			const category = window.location.pathname.split('/').pop();
+			const category = {{.EncodedName}};
             loadImages(category); // 初始加载第一页
 
             $(window).scroll(function() {
@@ -334,22 +536,73 @@ const indexTemplate = `<!DOCTYPE html>
 <body>
     <div class="container">
         <h1 class="my-4 text-center">{{.Config.Title}}</h1>
+        {{if ne .UserInfo.Provider ""}}<div class="text-center text-muted mb-3">{{if ne .UserInfo.Username ""}}当前用户：{{.UserInfo.Username}} · {{end}}{{if eq .UserInfo.Role "admin"}}<a href="/admin/">管理</a> · {{end}}<a href="/account/tokens">访问令牌</a> · {{if eq .UserInfo.Provider "user"}}<a href="/account/2fa">两步验证</a> · {{end}}<form method="POST" action="/logout" class="d-inline">{{csrfField}}<button type="submit" class="btn btn-link p-0 border-0 align-baseline">退出登录</button></form></div>{{end}}
+        <div class="d-flex flex-wrap justify-content-between gap-2 mb-3">
+            <form method="GET" action="/" class="d-flex gap-2" role="search">
+                <input type="search" name="q" value="{{.Query}}" maxlength="100" class="form-control form-control-sm" placeholder="搜索分类、标签或文件名">
+                <button type="submit" class="btn btn-sm btn-outline-primary text-nowrap">搜索</button>
+            </form>
+            {{if not .Query}}
+            <form method="GET" class="d-flex gap-2">
+                <select name="sort" class="form-select form-select-sm w-auto" onchange="this.form.submit()">
+                    {{range .SortOptions}}<option value="{{.Value}}"{{if eq .Value $.Sort.By}} selected{{end}}>{{.Label}}</option>{{end}}
+                </select>
+                <select name="order" class="form-select form-select-sm w-auto" onchange="this.form.submit()">
+                    <option value="asc"{{if eq .Sort.Order "asc"}} selected{{end}}>升序</option>
+                    <option value="desc"{{if eq .Sort.Order "desc"}} selected{{end}}>降序</option>
+                </select>
+            </form>
+            {{end}}
+        </div>
+        {{if .Query}}
+        <p class="text-muted">“{{.Query}}”共 {{.Total}} 个结果 · <a href="/">返回全部分类</a></p>
+        <div class="row">
+			{{range .Results}}
+				<div class="col-md-3 col-sm-6">
+					<div class="category-card">
+						{{if eq .Type "category"}}
+						<a href="/category/{{.EncodedCategory}}" style="text-decoration: none;">
+							<img data-src="/thumbs/{{.EncodedCategory}}/{{.CoverImage}}?w=480"
+							 src="data:image/svg+xml,%3Csvg xmlns='http://www.w3.org/2000/svg' viewBox='0 0 1 1'%3E%3C/svg%3E"
+							 class="img-fluid lazy" loading="lazy" alt="{{.Title}}">
+							<p>{{.Title}}<br><small class="text-muted">{{.Category}}</small></p>
+						</a>
+						{{else}}
+						<a href="/images/{{.EncodedCategory}}/{{.EncodedName}}" target="_blank" style="text-decoration: none;">
+							<img data-src="/thumbs/{{.EncodedCategory}}/{{.EncodedName}}?w=480"
+							 src="data:image/svg+xml,%3Csvg xmlns='http://www.w3.org/2000/svg' viewBox='0 0 1 1'%3E%3C/svg%3E"
+							 class="img-fluid lazy" loading="lazy" alt="{{.Name}}">
+						</a>
+						<p>{{.Name}}<br><a href="/category/{{.EncodedCategory}}" class="small text-muted">{{.CategoryTitle}}</a></p>
+						{{end}}
+					</div>
+				</div>
+			{{end}}
+        </div>
+        {{if or .PrevPage .NextPage}}
+        <nav class="d-flex justify-content-center gap-3 mb-4">
+            {{if .PrevPage}}<a href="/?q={{.Query}}&page={{.PrevPage}}">上一页</a>{{end}}
+            {{if .NextPage}}<a href="/?q={{.Query}}&page={{.NextPage}}">下一页</a>{{end}}
+        </nav>
+        {{end}}
+        {{else}}
         <div class="row">
 			{{range .Category}}
 				<div class="col-md-3 col-sm-6">
 					<div class="category-card">
 						<a href="/category/{{.EncodedName}}" style="text-decoration: none;">
-							<img data-src="/images/{{.EncodedName}}/{{.CoverImage}}"
+							<img data-src="/thumbs/{{.EncodedName}}/{{.CoverImage}}?w=480"
 							 src="data:image/svg+xml,%3Csvg xmlns='http://www.w3.org/2000/svg' viewBox='0 0 1 1'%3E%3C/svg%3E"
-							 class="img-fluid lazy" loading="lazy" alt="{{.Name}}">
-							<p>{{.Name}}</p>
+							 class="img-fluid lazy" loading="lazy" alt="{{.Title}}">
+							<p>{{.Title}}</p>
 						</a>
 					</div>
 				</div>
 			{{end}}
         </div>
+        {{end}}
     </div>
-        {{if ne .Config.LinuxdoEnable "false"}}
+        {{if ne .UserInfo.ProviderName ""}}
     <div class="modal fade" id="exampleModal" tabindex="-1" aria-labelledby="exampleModalLabel" aria-hidden="true">
     <div class="modal-dialog">
         <div class="modal-content">
@@ -357,7 +610,7 @@ const indexTemplate = `<!DOCTYPE html>
         <div class="text-center">
         <img src="data:image/gif;base64,R0lGODlhAQABAAAAACH5BAEKAAEALAAAAAABAAEAAAICTAEAOw==" id="avatar" alt="Logo" style="width: 100px; height: 100px; border-radius: 50%;"><br>
         </div>
-            <p class="text-center">欢迎来自Linux.do的佬友：<span id="username" style="color: #FF9800;">XXX</span></p>
+            <p class="text-center">{{if eq .UserInfo.Provider "linuxdo"}}欢迎来自Linux.do的佬友{{else}}欢迎来自{{.UserInfo.ProviderName}}的用户{{end}}：<span id="username" style="color: #FF9800;">XXX</span></p>
         </div>
         <div class="modal-footer">
             <button type="button" class="btn btn-secondary" data-bs-dismiss="modal">Close</button>
@@ -452,7 +705,7 @@ const indexTemplate = `<!DOCTYPE html>
             }
             return false; 
         }
-        {{if ne .Config.LinuxdoEnable "false"}}
+        {{if ne .UserInfo.ProviderName ""}}
         $('#exampleModal').on('hidden.bs.modal', function () {
             if (!checkCookie("modalClosed")) {
                 document.cookie = "modalClosed=true; path=/; max-age=" + 60 * 60 * 24; // 1天有效期
@@ -461,11 +714,11 @@ const indexTemplate = `<!DOCTYPE html>
         {{end}}
 
         $(document).ready(function() {
-                    {{if ne .Config.LinuxdoEnable "false"}}
+                    {{if ne .UserInfo.ProviderName ""}}
             if (!checkCookie("modalClosed")) {
                 $('#username').text("{{.UserInfo.Username}}");
                 $('#avatar').attr("src", "{{.UserInfo.AvatarURL}}");
-                {{if ne .UserInfo.Username ""}}$('#exampleModal').modal('show');{{end}}
+                $('#exampleModal').modal('show');
             }
             {{end}}
         });
@@ -480,15 +733,22 @@ const categoryTemplate = `<!DOCTYPE html>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <meta name="description" content="{{.Category}} 的图片集合， {{.Config.Title}}">
+    <meta name="description" content="{{.Title}} 的图片集合， {{.Config.Title}}">
     <meta name="keywords" content="{{.Category}}, 图片, 相册">
-    <title>{{.Category}} - {{.Config.Title}} - 图片合集</title>
+    <title>{{.Title}} - {{.Config.Title}} - 图片合集</title>
     <link rel="stylesheet" href="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/css/bootstrap.min.css">
     <link rel="stylesheet" href="https://jsd.051214.xyz/npm/@fancyapps/fancybox@3.5.7/dist/jquery.fancybox.min.css">
 	<link rel="shortcut icon" type="image/x-icon" href="{{.Config.Icon}}" />
 	<style>
-        .image-card { margin-bottom: 20px; }
+        .image-card { position: relative; margin-bottom: 20px; }
+        .category-card { text-align: center; margin-bottom: 20px; }
+        .category-card img { width: 100%; height: auto; border-radius: 8px; }
+        .category-card p { margin-top: 10px; font-size: 1.1em; }
         .image-card img { width: 100%; height: auto; border-radius: 8px; }
+        .select-box { display: none; position: absolute; top: 8px; left: 8px; z-index: 10; width: 1.4em; height: 1.4em; }
+        .select-action { display: none; }
+        body.selecting .select-box { display: block; }
+        body.selecting .select-action { display: inline-block; }
 		#back-buttons {position: fixed;bottom: 20px;right: 20px;display: flex;flex-direction: column;gap: 10px;z-index: 1000;}
 		#back-buttons button {padding: 5px 10px;border: none;color: white;border-radius: 5px;cursor: pointer;font-size: 14px;transition: all 0.3s;}
 		#back-buttons button:hover {background-color: #bdc5ca;}
@@ -503,21 +763,63 @@ const categoryTemplate = `<!DOCTYPE html>
 </head>
 <body>
     <div class="container">
-        <h1 class="my-4 text-center">{{.Category}}</h1>
+        <h1 class="my-4 text-center">{{.Title}}</h1>
+        {{if .Description}}<p class="text-center text-muted">{{.Description}}</p>{{end}}
+        {{if .Tags}}<p class="text-center">{{range .Tags}}<span class="badge bg-secondary me-1">{{.}}</span>{{end}}</p>{{end}}
+        <nav aria-label="breadcrumb">
+            <ol class="breadcrumb">
+                <li class="breadcrumb-item"><a href="/">首页</a></li>
+                {{range .Breadcrumbs}}{{if eq .EncodedName $.EncodedName}}<li class="breadcrumb-item active" aria-current="page">{{.Name}}</li>{{else}}<li class="breadcrumb-item"><a href="/category/{{.EncodedName}}">{{.Name}}</a></li>{{end}}
+                {{end}}
+            </ol>
+        </nav>
+        <form method="GET" class="d-flex justify-content-end gap-2 mb-3">
+            <select name="sort" class="form-select form-select-sm w-auto" onchange="this.form.submit()">
+                {{range .SortOptions}}<option value="{{.Value}}"{{if eq .Value $.Sort.By}} selected{{end}}>{{.Label}}</option>{{end}}
+            </select>
+            <select name="order" class="form-select form-select-sm w-auto" onchange="this.form.submit()">
+                <option value="asc"{{if eq .Sort.Order "asc"}} selected{{end}}>升序</option>
+                <option value="desc"{{if eq .Sort.Order "desc"}} selected{{end}}>降序</option>
+            </select>
+            {{if .Images}}
+            <a href="/download/{{.EncodedName}}.zip" class="btn btn-sm btn-outline-secondary">下载全部</a>
+            <button type="button" class="btn btn-sm btn-outline-secondary" onclick="toggleSelect()">选择下载</button>
+            <button type="button" class="btn btn-sm btn-primary select-action" onclick="downloadSelected()">下载所选</button>
+            {{end}}
+        </form>
+        <form id="download-form" method="POST" action="/download/{{.EncodedName}}.zip" class="d-none">{{csrfField}}</form>
+        {{if .Children}}
+        <div class="row">
+            {{range .Children}}
+                <div class="col-md-3 col-sm-6">
+                    <div class="category-card">
+                        <a href="/category/{{.EncodedName}}" style="text-decoration: none;">
+                            <img data-src="/thumbs/{{.EncodedName}}/{{.CoverImage}}?w=480" alt="{{.Title}}" class="img-fluid lazy" loading="lazy"
+							src="data:image/svg+xml,%3Csvg xmlns='http://www.w3.org/2000/svg' viewBox='0 0 1 1'%3E%3C/svg%3E">
+                            <p>{{.Title}}</p>
+                        </a>
+                    </div>
+                </div>
+            {{end}}
+        </div>
+        {{end}}
         <div class="row">
             {{range .Images}}
                 <div class="col-md-3 col-sm-6">
                     <div class="image-card">
-                        <a href="/images/{{$.Category}}/{{.Name}}" data-fancybox="{{$.Category}}">
-                            <img data-src="/images/{{$.Category}}/{{.Name}}" alt="{{.Name}}" class="img-fluid lazy" loading="lazy" 
+                        <input type="checkbox" class="form-check-input select-box" value="{{.Name}}">
+                        <a href="/images/{{$.EncodedName}}/{{.EncodedName}}" data-fancybox="{{$.Category}}"{{if ne .Kind "image"}} data-type="video" data-video-format="{{.Mime}}"{{end}}>
+                            <img data-src="{{if eq .Type "gif"}}/images/{{$.EncodedName}}/{{.EncodedName}}{{else}}/thumbs/{{$.EncodedName}}/{{.EncodedName}}?w=480{{end}}" alt="{{.Name}}" class="img-fluid lazy" loading="lazy" 
 							src="data:image/svg+xml,%3Csvg xmlns='http://www.w3.org/2000/svg' viewBox='0 0 1 1'%3E%3C/svg%3E"
-							{{if eq .Type "gif"}}data-type="image/gif"{{end}}>
+							{{if eq .Type "gif"}}data-type="image/gif"{{end}}
+							{{if .Width}}width="{{.Width}}" height="{{.Height}}"{{end}}>
                         </a>
                     </div>
                 </div>
             {{end}}
         </div>
     </div>
+    <div id="exif-panel" style="display: none; position: fixed; top: 60px; left: 20px; z-index: 100000; max-width: 320px; padding: 12px 16px; border-radius: 8px; background: rgba(0, 0, 0, 0.75); color: #fff; font-size: 14px;"></div>
 	<div id="back-buttons">
     <button id="back-btn" onclick="back()">⬅</button>
     <button id="top-btn" onclick="scrollToTop()">🔝</button>
@@ -527,6 +829,47 @@ const categoryTemplate = `<!DOCTYPE html>
     <script src="https://jsd.051214.xyz/npm/@fancyapps/fancybox@3.5.7/dist/jquery.fancybox.min.js"></script>
     <script src="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/js/bootstrap.bundle.min.js"></script>
     <script>
+        // 查看大图时的图片信息面板
+        $.fancybox.defaults.btnTpl.info = '<button data-fancybox-info class="fancybox-button" title="图片信息">ℹ</button>';
+        $.fancybox.defaults.buttons = ['info', 'zoom', 'slideShow', 'fullScreen', 'thumbs', 'close'];
+        $.fancybox.defaults.afterShow = function(instance, slide) {
+            loadImageInfo(slide.src);
+        };
+        $.fancybox.defaults.afterClose = function() {
+            $('#exif-panel').hide();
+        };
+        $(document).on('click', '[data-fancybox-info]', function() {
+            $('#exif-panel').toggle();
+        });
+
+        function loadImageInfo(src) {
+            const $panel = $('#exif-panel');
+            $panel.text('加载中...');
+            $.getJSON(src.replace(/^\/images\//, '/api/image/'), function(data) {
+                const image = data.image;
+                const exif = data.exif;
+                const rows = [['文件', image.Name]];
+                if (image.Width) rows.push(['尺寸', image.Width + ' × ' + image.Height]);
+                rows.push(['大小', (image.Size / 1024 / 1024).toFixed(2) + ' MB']);
+                if (exif.capture_time) rows.push(['拍摄时间', new Date(exif.capture_time).toLocaleString()]);
+                const camera = [exif.make, exif.model].filter(Boolean).join(' ');
+                if (camera) rows.push(['相机', camera]);
+                if (exif.lens_model) rows.push(['镜头', exif.lens_model]);
+                const exposure = [
+                    exif.focal_length ? exif.focal_length + 'mm' : '',
+                    exif.f_number ? 'f/' + exif.f_number : '',
+                    exif.exposure_time ? exif.exposure_time + 's' : '',
+                    exif.iso ? 'ISO ' + exif.iso : ''
+                ].filter(Boolean).join('  ');
+                if (exposure) rows.push(['曝光', exposure]);
+                if (exif.exposure_bias) rows.push(['曝光补偿', exif.exposure_bias + ' EV']);
+                $panel.empty();
+                rows.forEach(row => $panel.append($('<div>').text(row[0] + '：' + row[1])));
+            }).fail(function() {
+                $panel.text('无法读取图片信息');
+            });
+        }
+
 	(function() {
         'use strict';
         
@@ -613,6 +956,22 @@ const categoryTemplate = `<!DOCTYPE html>
 		function scrollToTop() {
 		    window.scrollTo({ top: 0, behavior: 'smooth' });
 		}
+        // 勾选图片后通过表单提交打包下载
+        function toggleSelect() {
+            document.body.classList.toggle('selecting');
+        }
+        function downloadSelected() {
+            const $form = $('#download-form');
+            $form.find('input[name="name"]').remove();
+            $('.select-box:checked').each(function() {
+                $form.append($('<input type="hidden" name="name">').val(this.value));
+            });
+            if ($form.find('input[name="name"]').length === 0) {
+                alert('请先勾选要下载的文件');
+                return;
+            }
+            $form.submit();
+        }
 		function back() {
 			history.back();
 		}
@@ -635,9 +994,20 @@ const loginTemplate = `<!DOCTYPE html>
             <div class="col-md-4">
                 <div class="card shadow">
                     <div class="card-body">
-                    {{if ne .Secure "false"}}
-                        <h3 class="card-title mb-4">请输入访问密码</h3>
+                    {{if ne .Config.Secure "false"}}
+                        <h3 class="card-title mb-4">{{if .MultiUser}}用户登录{{else}}请输入访问密码{{end}}</h3>
                         <form method="POST">
+                            {{csrfField}}
+                            {{if .MultiUser}}
+                            <div class="mb-3">
+                                <input type="text" 
+                                       name="username" 
+                                       class="form-control"
+                                       placeholder="用户名"
+                                       autocomplete="username"
+                                       required>
+                            </div>
+                            {{end}}
                             <div class="mb-3">
                                 <input type="password" 
                                        name="password" 
@@ -648,9 +1018,11 @@ const loginTemplate = `<!DOCTYPE html>
                             <button type="submit" class="btn btn-primary w-100">登录</button>
                         </form>
                         {{end}}
-                        {{if ne .LinuxdoEnable "false"}}
-                        {{if ne .Secure "false"}}OR{{end}}
-                        <a href="/oauth2/linxdo" class="btn btn-primary w-100" style="background-color: #4cad50;border: solid;">
+                        {{if .Providers}}
+                        {{if ne .Config.Secure "false"}}OR{{end}}
+                        {{range .Providers}}
+                        {{if eq .Preset "linuxdo"}}
+                        <a href="/oauth2/{{.Name}}" class="btn btn-primary w-100 mb-2" style="background-color: #4cad50;border: solid;">
                         <svg width="27" height="27" viewBox="0 0 120 120" xmlns="http://www.w3.org/2000/svg">
                             <clipPath id="a"><circle cx="60" cy="60" r="47"/></clipPath>
                             <circle fill="#f0f0f0" cx="60" cy="60" r="50"/>
@@ -658,7 +1030,11 @@ const loginTemplate = `<!DOCTYPE html>
                             <rect fill="#f0f0f0" clip-path="url(#a)" x="10" y="40" width="100" height="40"/>
                             <rect fill="#ffb003" clip-path="url(#a)" x="10" y="80" width="100" height="30"/>
                         </svg>
-                        Linux do 登录</a>
+                        {{.DisplayName}} 登录</a>
+                        {{else}}
+                        <a href="/oauth2/{{.Name}}" class="btn btn-outline-primary w-100 mb-2">{{.DisplayName}} 登录</a>
+                        {{end}}
+                        {{end}}
                     {{end}}
                     </div>
                 </div>
@@ -667,3 +1043,422 @@ const loginTemplate = `<!DOCTYPE html>
     </div>
 </body>
 </html>`
+
+const deniedTemplate = `<!DOCTYPE html>
+<html lang="zh">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>无法访问 - {{.Config.Title}}</title>
+    <link rel="stylesheet" href="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/css/bootstrap.min.css">
+    <link rel="shortcut icon" type="image/x-icon" href="{{.Config.Icon}}" />
+</head>
+<body class="bg-light">
+    <div class="container mt-5">
+        <div class="row justify-content-center">
+            <div class="col-md-4">
+                <div class="card shadow">
+                    <div class="card-body text-center">
+                        <h3 class="card-title mb-4">无法访问</h3>
+                        <p>{{.Provider}} 用户 <strong>{{.Username}}</strong> 暂无访问权限。</p>
+                        <p class="text-muted">{{.Reason}}</p>
+                        <a href="/login" class="btn btn-primary w-100">返回登录</a>
+                    </div>
+                </div>
+            </div>
+        </div>
+    </div>
+</body>
+</html>`
+
+// 管理页面模板
+const adminTemplate = `<!DOCTYPE html>
+<html lang="zh">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>管理 - {{.Config.Title}}</title>
+    <link rel="stylesheet" href="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/css/bootstrap.min.css">
+    <link rel="shortcut icon" type="image/x-icon" href="{{.Config.Icon}}" />
+</head>
+<body class="bg-light">
+    <div class="container">
+        <h1 class="my-4 text-center">管理</h1>
+        <div class="text-center mb-3"><a href="/">返回首页</a> · <a href="/account/tokens">我的访问令牌</a> · <form method="POST" action="/logout" class="d-inline">{{csrfField}}<button type="submit" class="btn btn-link p-0 border-0 align-baseline">退出登录</button></form></div>
+
+        <div class="card shadow mb-4">
+            <div class="card-body">
+                <h4 class="card-title">登录会话</h4>
+                <div class="table-responsive">
+                <table class="table table-sm align-middle">
+                    <thead>
+                        <tr><th>用户</th><th>IP</th><th>客户端</th><th>登录时间</th><th>过期时间</th><th></th></tr>
+                    </thead>
+                    <tbody>
+                    {{range .Sessions}}
+                        <tr>
+                            <td>{{.User}}{{if .Current}} <span class="badge bg-success">当前</span>{{end}}</td>
+                            <td>{{.IP}}</td>
+                            <td class="text-truncate" style="max-width: 240px;" title="{{.UserAgent}}">{{.UserAgent}}</td>
+                            <td>{{.CreatedAt.Format "2006-01-02 15:04:05"}}</td>
+                            <td>{{.ExpiresAt.Format "2006-01-02 15:04:05"}}</td>
+                            <td class="text-nowrap">
+                                <form method="POST" action="/admin/sessions/revoke" class="d-inline">
+                                    {{csrfField}}
+                                    <input type="hidden" name="id" value="{{.ID}}">
+                                    <button type="submit" class="btn btn-sm btn-outline-danger">撤销</button>
+                                </form>
+                                <form method="POST" action="/admin/sessions/revoke" class="d-inline">
+                                    {{csrfField}}
+                                    <input type="hidden" name="subject" value="{{.Subject}}">
+                                    <button type="submit" class="btn btn-sm btn-outline-secondary">撤销该用户全部会话</button>
+                                </form>
+                            </td>
+                        </tr>
+                    {{else}}
+                        <tr><td colspan="6" class="text-center text-muted">暂无会话</td></tr>
+                    {{end}}
+                    </tbody>
+                </table>
+                </div>
+            </div>
+        </div>
+
+        <div class="card shadow mb-4">
+            <div class="card-body">
+                <h4 class="card-title">分享链接</h4>
+                <form method="POST" action="/admin/shares/create" class="row g-2 align-items-end mb-3">
+                    {{csrfField}}
+                    <div class="col-md-4">
+                        <label class="form-label">分类</label>
+                        <select name="category" class="form-select" required>
+                        {{range .Categories}}<option value="{{.Name}}">{{.Name}}</option>{{end}}
+                        </select>
+                    </div>
+                    <div class="col-md-2">
+                        <label class="form-label">有效期（小时）</label>
+                        <input type="number" name="hours" class="form-control" value="24" min="1" required>
+                    </div>
+                    <div class="col-md-2">
+                        <label class="form-label">访问次数上限</label>
+                        <input type="number" name="max_views" class="form-control" value="0" min="0" title="0 表示不限">
+                    </div>
+                    <div class="col-md-2">
+                        <label class="form-label">访问密码</label>
+                        <input type="text" name="password" class="form-control" placeholder="可选">
+                    </div>
+                    <div class="col-md-2">
+                        <button type="submit" class="btn btn-primary w-100">生成链接</button>
+                    </div>
+                </form>
+                <div class="table-responsive">
+                <table class="table table-sm align-middle">
+                    <thead>
+                        <tr><th>分类</th><th>链接</th><th>创建者</th><th>过期时间</th><th>访问次数</th><th>密码</th><th></th></tr>
+                    </thead>
+                    <tbody>
+                    {{range .Shares}}
+                        <tr>
+                            <td>{{.Category}}</td>
+                            <td><input type="text" class="form-control form-control-sm" value="{{.URL}}" readonly onclick="this.select()"></td>
+                            <td>{{.CreatedBy}}</td>
+                            <td>{{.ExpiresAt.Format "2006-01-02 15:04:05"}}</td>
+                            <td>{{.Views}}{{if gt .MaxViews 0}} / {{.MaxViews}}{{end}}</td>
+                            <td>{{if ne .PasswordHash ""}}有{{else}}无{{end}}</td>
+                            <td>
+                                <form method="POST" action="/admin/shares/revoke" class="d-inline">
+                                    {{csrfField}}
+                                    <input type="hidden" name="id" value="{{.ID}}">
+                                    <button type="submit" class="btn btn-sm btn-outline-danger">撤销</button>
+                                </form>
+                            </td>
+                        </tr>
+                    {{else}}
+                        <tr><td colspan="7" class="text-center text-muted">暂无分享链接</td></tr>
+                    {{end}}
+                    </tbody>
+                </table>
+                </div>
+            </div>
+        </div>
+
+        <div class="card shadow mb-4">
+            <div class="card-body">
+                <h4 class="card-title">访问令牌</h4>
+                <div class="table-responsive">
+                <table class="table table-sm align-middle">
+                    <thead>
+                        <tr><th>名称</th><th>所有者</th><th>权限</th><th>过期时间</th><th>最近使用</th><th></th></tr>
+                    </thead>
+                    <tbody>
+                    {{range .Tokens}}
+                        <tr>
+                            <td>{{.Name}}</td>
+                            <td>{{.OwnerLabel}}</td>
+                            <td>{{if eq .Scope "admin"}}管理{{else}}只读{{end}}</td>
+                            <td>{{if .ExpiresAt.IsZero}}永不过期{{else}}{{.ExpiresAt.Format "2006-01-02 15:04:05"}}{{end}}</td>
+                            <td>{{if .LastUsedAt.IsZero}}从未使用{{else}}{{.LastUsedAt.Format "2006-01-02 15:04:05"}}{{end}}</td>
+                            <td>
+                                <form method="POST" action="/admin/tokens/revoke" class="d-inline">
+                                    {{csrfField}}
+                                    <input type="hidden" name="id" value="{{.ID}}">
+                                    <button type="submit" class="btn btn-sm btn-outline-danger">撤销</button>
+                                </form>
+                            </td>
+                        </tr>
+                    {{else}}
+                        <tr><td colspan="6" class="text-center text-muted">暂无访问令牌</td></tr>
+                    {{end}}
+                    </tbody>
+                </table>
+                </div>
+            </div>
+        </div>
+    </div>
+</body>
+</html>`
+
+// 分享链接密码页面模板
+const sharePasswordTemplate = `<!DOCTYPE html>
+<html lang="zh">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Category}} - {{.Config.Title}}</title>
+    <link rel="stylesheet" href="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/css/bootstrap.min.css">
+    <link rel="shortcut icon" type="image/x-icon" href="{{.Config.Icon}}" />
+</head>
+<body class="bg-light">
+    <div class="container mt-5">
+        <div class="row justify-content-center">
+            <div class="col-md-4">
+                <div class="card shadow">
+                    <div class="card-body">
+                        <h3 class="card-title mb-4">{{.Category}}</h3>
+                        <p class="text-muted">该分享需要密码才能查看</p>
+                        {{if .Message}}<div class="alert alert-danger py-2">{{.Message}}</div>{{end}}
+                        <form method="POST">
+                            {{csrfField}}
+                            <div class="mb-3">
+                                <input type="password" 
+                                       name="password" 
+                                       class="form-control"
+                                       placeholder="密码"
+                                       required>
+                            </div>
+                            <button type="submit" class="btn btn-primary w-100">查看</button>
+                        </form>
+                    </div>
+                </div>
+            </div>
+        </div>
+    </div>
+</body>
+</html>`
+
+// 个人访问令牌页面模板
+const tokensTemplate = `<!DOCTYPE html>
+<html lang="zh">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>访问令牌 - {{.Config.Title}}</title>
+    <link rel="stylesheet" href="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/css/bootstrap.min.css">
+    <link rel="shortcut icon" type="image/x-icon" href="{{.Config.Icon}}" />
+</head>
+<body class="bg-light">
+    <div class="container">
+        <h1 class="my-4 text-center">访问令牌</h1>
+        <div class="text-center mb-3"><a href="/">返回首页</a> · <form method="POST" action="/logout" class="d-inline">{{csrfField}}<button type="submit" class="btn btn-link p-0 border-0 align-baseline">退出登录</button></form></div>
+
+        {{if .NewToken}}
+        <div class="alert alert-success">
+            <p class="mb-2">{{.Message}}</p>
+            <input type="text" class="form-control" value="{{.NewToken}}" readonly onclick="this.select()">
+        </div>
+        {{end}}
+
+        <div class="card shadow mb-4">
+            <div class="card-body">
+                <p class="text-muted">在请求头中携带 <code>Authorization: Bearer 令牌</code> 即可调用 <code>/api/index</code> 与 <code>/api/category/</code> 接口。</p>
+                <form method="POST" class="row g-2 align-items-end mb-3">
+                    {{csrfField}}
+                    <input type="hidden" name="action" value="create">
+                    <div class="col-md-4">
+                        <label class="form-label">名称</label>
+                        <input type="text" name="name" class="form-control" placeholder="例如：同步脚本">
+                    </div>
+                    <div class="col-md-3">
+                        <label class="form-label">权限</label>
+                        <select name="scope" class="form-select">
+                            <option value="read">只读</option>
+                            {{if eq .UserInfo.Role "admin"}}<option value="admin">管理</option>{{end}}
+                        </select>
+                    </div>
+                    <div class="col-md-3">
+                        <label class="form-label">有效期（天）</label>
+                        <input type="number" name="days" class="form-control" value="30" min="0" title="0 表示永不过期">
+                    </div>
+                    <div class="col-md-2">
+                        <button type="submit" class="btn btn-primary w-100">创建令牌</button>
+                    </div>
+                </form>
+                <div class="table-responsive">
+                <table class="table table-sm align-middle">
+                    <thead>
+                        <tr><th>名称</th><th>权限</th><th>创建时间</th><th>过期时间</th><th>最近使用</th><th></th></tr>
+                    </thead>
+                    <tbody>
+                    {{range .Tokens}}
+                        <tr>
+                            <td>{{.Name}}</td>
+                            <td>{{if eq .Scope "admin"}}管理{{else}}只读{{end}}</td>
+                            <td>{{.CreatedAt.Format "2006-01-02 15:04:05"}}</td>
+                            <td>{{if .ExpiresAt.IsZero}}永不过期{{else}}{{.ExpiresAt.Format "2006-01-02 15:04:05"}}{{end}}</td>
+                            <td>{{if .LastUsedAt.IsZero}}从未使用{{else}}{{.LastUsedAt.Format "2006-01-02 15:04:05"}}{{end}}</td>
+                            <td>
+                                <form method="POST" class="d-inline">
+                                    {{csrfField}}
+                                    <input type="hidden" name="action" value="revoke">
+                                    <input type="hidden" name="id" value="{{.ID}}">
+                                    <button type="submit" class="btn btn-sm btn-outline-danger">撤销</button>
+                                </form>
+                            </td>
+                        </tr>
+                    {{else}}
+                        <tr><td colspan="6" class="text-center text-muted">暂无访问令牌</td></tr>
+                    {{end}}
+                    </tbody>
+                </table>
+                </div>
+            </div>
+        </div>
+    </div>
+</body>
+</html>`
+
+// 两步验证登录页面模板
+const totpLoginTemplate = `<!DOCTYPE html>
+<html lang="zh">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>两步验证 - {{.Config.Title}}</title>
+    <link rel="stylesheet" href="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/css/bootstrap.min.css">
+    <link rel="shortcut icon" type="image/x-icon" href="{{.Config.Icon}}" />
+</head>
+<body class="bg-light">
+    <div class="container mt-5">
+        <div class="row justify-content-center">
+            <div class="col-md-5">
+                <div class="card shadow">
+                    <div class="card-body">
+                        <h3 class="card-title mb-4">两步验证</h3>
+                        {{if .RecoveryCodes}}
+                        <div class="alert alert-warning">两步验证已启用。请妥善保存以下恢复码，每个恢复码只能使用一次，丢失认证器时可用于登录：</div>
+                        <pre class="bg-light p-3 text-center">{{range .RecoveryCodes}}{{.}}
+{{end}}</pre>
+                        <a href="/" class="btn btn-primary w-100">我已保存，进入首页</a>
+                        {{else}}
+                        {{if .Message}}<div class="alert alert-danger py-2">{{.Message}}</div>{{end}}
+                        {{if .Enrolled}}
+                        <p class="text-muted">请输入 <strong>{{.Username}}</strong> 认证器应用中的6位动态码，或使用恢复码。</p>
+                        {{else}}
+                        <p class="text-muted">管理员账号必须启用两步验证。请使用认证器应用扫描二维码，或手动输入密钥，然后输入生成的6位动态码。</p>
+                        <div id="qrcode" class="d-flex justify-content-center mb-3"></div>
+                        <p class="text-center"><code>{{.Secret}}</code></p>
+                        {{end}}
+                        <form method="POST">
+                            {{csrfField}}
+                            <div class="mb-3">
+                                <input type="text" 
+                                       name="code" 
+                                       class="form-control"
+                                       placeholder="{{if .Enrolled}}动态码或恢复码{{else}}动态码{{end}}"
+                                       autocomplete="one-time-code"
+                                       autofocus
+                                       required>
+                            </div>
+                            <button type="submit" class="btn btn-primary w-100">验证</button>
+                        </form>
+                        {{end}}
+                    </div>
+                </div>
+            </div>
+        </div>
+    </div>
+    {{if and (not .Enrolled) (not .RecoveryCodes)}}
+    <script src="https://jsd.051214.xyz/npm/qrcodejs@1.0.0/qrcode.min.js"></script>
+    <script>
+        new QRCode(document.getElementById("qrcode"), { text: "{{.URI}}", width: 180, height: 180 });
+    </script>
+    {{end}}
+</body>
+</html>`
+
+// 个人两步验证设置页面模板
+const totpAccountTemplate = `<!DOCTYPE html>
+<html lang="zh">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>两步验证 - {{.Config.Title}}</title>
+    <link rel="stylesheet" href="https://jsd.051214.xyz/npm/bootstrap@5.1.3/dist/css/bootstrap.min.css">
+    <link rel="shortcut icon" type="image/x-icon" href="{{.Config.Icon}}" />
+</head>
+<body class="bg-light">
+    <div class="container">
+        <h1 class="my-4 text-center">两步验证</h1>
+        <div class="text-center mb-3"><a href="/">返回首页</a> · <form method="POST" action="/logout" class="d-inline">{{csrfField}}<button type="submit" class="btn btn-link p-0 border-0 align-baseline">退出登录</button></form></div>
+        <div class="row justify-content-center">
+            <div class="col-md-6">
+                <div class="card shadow mb-4">
+                    <div class="card-body">
+                        {{if .Message}}<div class="alert alert-danger py-2">{{.Message}}</div>{{end}}
+                        {{if .RecoveryCodes}}
+                        <div class="alert alert-warning">请妥善保存以下恢复码，每个恢复码只能使用一次，之前的恢复码已失效：</div>
+                        <pre class="bg-light p-3 text-center">{{range .RecoveryCodes}}{{.}}
+{{end}}</pre>
+                        {{end}}
+                        {{if .Enrolled}}
+                        <p>两步验证<span class="badge bg-success ms-1">已启用</span>，剩余恢复码 {{.RecoveryLeft}} 个。</p>
+                        <form method="POST" class="row g-2">
+                            {{csrfField}}
+                            <div class="col-md-6">
+                                <input type="text" name="code" class="form-control" placeholder="当前动态码" autocomplete="one-time-code" required>
+                            </div>
+                            <div class="col-md-3">
+                                <button type="submit" name="action" value="recovery" class="btn btn-outline-primary w-100">重新生成恢复码</button>
+                            </div>
+                            <div class="col-md-3">
+                                <button type="submit" name="action" value="disable" class="btn btn-outline-danger w-100">停用</button>
+                            </div>
+                        </form>
+                        {{else}}
+                        <p>使用认证器应用扫描二维码，或手动输入密钥，然后输入生成的6位动态码以启用两步验证。</p>
+                        <div id="qrcode" class="d-flex justify-content-center mb-3"></div>
+                        <p class="text-center"><code>{{.Secret}}</code></p>
+                        <form method="POST" class="row g-2">
+                            {{csrfField}}
+                            <input type="hidden" name="action" value="enable">
+                            <div class="col-md-8">
+                                <input type="text" name="code" class="form-control" placeholder="动态码" autocomplete="one-time-code" required>
+                            </div>
+                            <div class="col-md-4">
+                                <button type="submit" class="btn btn-primary w-100">启用</button>
+                            </div>
+                        </form>
+                        {{end}}
+                    </div>
+                </div>
+            </div>
+        </div>
+    </div>
+    {{if not .Enrolled}}
+    <script src="https://jsd.051214.xyz/npm/qrcodejs@1.0.0/qrcode.min.js"></script>
+    <script>
+        new QRCode(document.getElementById("qrcode"), { text: "{{.URI}}", width: 180, height: 180 });
+    </script>
+    {{end}}
+</body>
+</html>`
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// 密码验证通过后等待输入动态码的最长时间
+	totpPendingTTL    = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var (
+	// 每个用户最近一次使用的时间步，防止同一动态码被重放
+	totpLastStep   = make(map[string]int64)
+	totpLastStepMu sync.Mutex
+)
+
+func newTOTPSecret() string {
+	return totpEncoding.EncodeToString(generateRandomKey(20))
+}
+
+// 按 RFC 6238 计算指定时间步的动态码
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// 校验动态码，允许前后各一个时间步的误差，同一时间步只能使用一次
+func verifyTOTP(username, secret, code string) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return false
+	}
+	now := time.Now().Unix() / totpPeriod
+	totpLastStepMu.Lock()
+	defer totpLastStepMu.Unlock()
+	for step := now - 1; step <= now+1; step++ {
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			if step <= totpLastStep[username] {
+				return false
+			}
+			totpLastStep[username] = step
+			return true
+		}
+	}
+	return false
+}
+
+// 认证器应用使用的 otpauth:// 地址
+func totpProvisioningURI(username, secret string) string {
+	issuer := config.Title
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("period", strconv.Itoa(totpPeriod))
+	query.Set("digits", strconv.Itoa(totpDigits))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+username) + "?" + query.Encode()
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// 生成一组恢复码，返回明文与对应的哈希
+func newRecoveryCodes() ([]string, []string) {
+	var codes, hashes []string
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := hex.EncodeToString(generateRandomKey(5))
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes
+}
+
+// 使用恢复码，成功后该恢复码作废
+func useRecoveryCode(username, code string) bool {
+	hash := hashRecoveryCode(code)
+	used := false
+	updateAccount(username, func(account *Account) {
+		for i, h := range account.RecoveryCodes {
+			if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+				account.RecoveryCodes = append(account.RecoveryCodes[:i], account.RecoveryCodes[i+1:]...)
+				used = true
+				return
+			}
+		}
+	})
+	return used
+}
+
+// 账号是否必须启用两步验证
+func totpRequired(account Account) bool {
+	return account.Role == roleAdmin && config.AdminRequire2FA == "true"
+}
+
+// 单一访问密码无法绑定动态码，强制管理员两步验证时必须使用用户文件
+func checkAdminRequire2FA() {
+	if config.AdminRequire2FA == "true" && config.Secure != "false" && !multiUser() && !proxyAuthEnabled() {
+		log.Fatalf("admin_require_2fa 需要配置用户文件 %s，单一访问密码无法启用两步验证", usersFileName)
+	}
+}
+
+// 密码验证通过但尚未完成两步验证的用户
+func pendingTOTPUser(r *http.Request) (Account, bool) {
+	session, _ := store.Get(r, "session-name")
+	username, _ := session.Values["totp_pending_user"].(string)
+	since, _ := session.Values["totp_pending_since"].(int64)
+	if username == "" || time.Since(time.Unix(since, 0)) > totpPendingTTL {
+		return Account{}, false
+	}
+	return findAccount(username)
+}
+
+func setPendingTOTPUser(w http.ResponseWriter, r *http.Request, username string) {
+	session, _ := store.Get(r, "session-name")
+	session.Values["totp_pending_user"] = username
+	session.Values["totp_pending_since"] = time.Now().Unix()
+	delete(session.Values, "totp_enroll_secret")
+	session.Save(r, w)
+}
+
+func clearPendingTOTPUser(w http.ResponseWriter, r *http.Request) {
+	session, _ := store.Get(r, "session-name")
+	delete(session.Values, "totp_pending_user")
+	delete(session.Values, "totp_pending_since")
+	delete(session.Values, "totp_enroll_secret")
+	session.Save(r, w)
+}
+
+// 待启用的动态码密钥暂存在会话中，确认动态码后才写入用户文件
+func enrollSecret(w http.ResponseWriter, r *http.Request) string {
+	session, _ := store.Get(r, "session-name")
+	secret, _ := session.Values["totp_enroll_secret"].(string)
+	if secret == "" {
+		secret = newTOTPSecret()
+		session.Values["totp_enroll_secret"] = secret
+		session.Save(r, w)
+	}
+	return secret
+}
+
+// 启用两步验证并返回恢复码
+func enableTOTP(w http.ResponseWriter, r *http.Request, username, secret string) []string {
+	codes, hashes := newRecoveryCodes()
+	updateAccount(username, func(account *Account) {
+		account.TOTPSecret = secret
+		account.RecoveryCodes = hashes
+	})
+	session, _ := store.Get(r, "session-name")
+	delete(session.Values, "totp_enroll_secret")
+	session.Save(r, w)
+	log.Printf("用户 %s 已启用两步验证", username)
+	return codes
+}
+
+type totpPageData struct {
+	Config        Config
+	Username      string
+	Enrolled      bool
+	Secret        string
+	URI           string
+	RecoveryCodes []string
+	RecoveryLeft  int
+	Message       string
+}
+
+func renderTOTPPage(w http.ResponseWriter, r *http.Request, name, text string, data totpPageData) {
+	data.Config = config
+	tmpl := template.Must(template.New(name).Funcs(csrfFuncs(w, r)).Parse(text))
+	tmpl.Execute(w, data)
+}
+
+// 登录第二步：输入动态码或恢复码，未启用但被要求启用时先完成绑定
+func loginTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	account, ok := pendingTOTPUser(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	enrolled := account.TOTPSecret != ""
+	data := totpPageData{Username: account.Username, Enrolled: enrolled}
+	if !enrolled {
+		data.Secret = enrollSecret(w, r)
+		data.URI = totpProvisioningURI(account.Username, data.Secret)
+	}
+	if r.Method != http.MethodPost {
+		renderTOTPPage(w, r, "totp", totpLoginTemplate, data)
+		return
+	}
+
+	keys := []string{"ip:" + clientIP(r), "user:" + account.Username}
+	if wait := loginLockedFor(keys...); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "验证失败次数过多，请稍后重试", http.StatusTooManyRequests)
+		return
+	}
+
+	code := r.FormValue("code")
+	switch {
+	case !enrolled && verifyTOTP(account.Username, data.Secret, code):
+		data.RecoveryCodes = enableTOTP(w, r, account.Username, data.Secret)
+	case enrolled && verifyTOTP(account.Username, account.TOTPSecret, code):
+	case enrolled && useRecoveryCode(account.Username, code):
+		log.Printf("用户 %s 使用恢复码登录", account.Username)
+	default:
+		recordLoginFailure(keys...)
+		log.Printf("用户 %s 两步验证失败，来源IP：%s", account.Username, clientIP(r))
+		w.WriteHeader(http.StatusUnauthorized)
+		data.Message = "验证码错误"
+		renderTOTPPage(w, r, "totp", totpLoginTemplate, data)
+		return
+	}
+
+	resetLoginFailures(keys...)
+	clearPendingTOTPUser(w, r)
+	setAuthCookie(w, r, "user:"+account.Username)
+	if len(data.RecoveryCodes) > 0 {
+		// 首次绑定时展示恢复码，确认后再进入首页
+		data.Enrolled = true
+		renderTOTPPage(w, r, "totp", totpLoginTemplate, data)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// 个人两步验证设置：启用、停用及重新生成恢复码
+func accountTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user.Provider != "user" {
+		http.Error(w, "仅本地账号可以设置两步验证", http.StatusForbidden)
+		return
+	}
+	account, ok := findAccount(user.Username)
+	if !ok {
+		http.Error(w, "账号不存在", http.StatusNotFound)
+		return
+	}
+
+	data := totpPageData{Username: account.Username, Enrolled: account.TOTPSecret != ""}
+	if r.Method == http.MethodPost {
+		code := r.FormValue("code")
+		switch r.FormValue("action") {
+		case "enable":
+			secret := enrollSecret(w, r)
+			if data.Enrolled || !verifyTOTP(account.Username, secret, code) {
+				data.Message = "验证码错误"
+				break
+			}
+			data.RecoveryCodes = enableTOTP(w, r, account.Username, secret)
+			data.Enrolled = true
+		case "disable":
+			if totpRequired(account) {
+				data.Message = "管理员必须启用两步验证"
+				break
+			}
+			if !data.Enrolled || !verifyTOTP(account.Username, account.TOTPSecret, code) {
+				data.Message = "验证码错误"
+				break
+			}
+			updateAccount(account.Username, func(a *Account) {
+				a.TOTPSecret = ""
+				a.RecoveryCodes = nil
+			})
+			log.Printf("用户 %s 已停用两步验证", account.Username)
+			data.Enrolled = false
+		case "recovery":
+			if !data.Enrolled || !verifyTOTP(account.Username, account.TOTPSecret, code) {
+				data.Message = "验证码错误"
+				break
+			}
+			codes, hashes := newRecoveryCodes()
+			updateAccount(account.Username, func(a *Account) {
+				a.RecoveryCodes = hashes
+			})
+			data.RecoveryCodes = codes
+		default:
+			http.Error(w, "未知操作", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !data.Enrolled {
+		data.Secret = enrollSecret(w, r)
+		data.URI = totpProvisioningURI(account.Username, data.Secret)
+	}
+	if account, ok := findAccount(user.Username); ok {
+		data.RecoveryLeft = len(account.RecoveryCodes)
+	}
+	renderTOTPPage(w, r, "account-totp", totpAccountTemplate, data)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 开启 admin_require_2fa 后，未完成两步验证的管理员令牌不再有效
+func TestAdminRequire2FAExistingSessions(t *testing.T) {
+	accountsMu.Lock()
+	accounts = []Account{
+		{Username: "root", Role: roleAdmin},
+		{Username: "ops", Role: roleAdmin, TOTPSecret: newTOTPSecret()},
+		{Username: "guest", Role: roleViewer},
+	}
+	accountsMu.Unlock()
+	t.Cleanup(func() {
+		accountsMu.Lock()
+		accounts = nil
+		accountsMu.Unlock()
+		config.AdminRequire2FA = ""
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, subject := range []string{"password:", "user:root", "user:ops", "user:guest"} {
+		if _, ok := resolveUser(r, subject); !ok {
+			t.Errorf("未开启强制两步验证时 %s 应有效", subject)
+		}
+	}
+
+	config.AdminRequire2FA = "true"
+	for _, tt := range []struct {
+		subject string
+		ok      bool
+	}{
+		{"password:", false},
+		{"user:root", false},
+		{"user:ops", true},
+		{"user:guest", true},
+	} {
+		if _, ok := resolveUser(r, tt.subject); ok != tt.ok {
+			t.Errorf("%s: 有效性为 %v，期望 %v", tt.subject, ok, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+const usersFileName = "conf/users.yaml"
+
+const (
+	roleAdmin  = "admin"
+	roleViewer = "viewer"
+)
+
+// 本地账号，password 仅用于首次填写明文密码，启动时会自动转换为 password_hash
+type Account struct {
+	Username      string   `yaml:"username"`
+	Password      string   `yaml:"password,omitempty"`
+	PasswordHash  string   `yaml:"password_hash"`
+	Role          string   `yaml:"role"`
+	TOTPSecret    string   `yaml:"totp_secret,omitempty"`    // 两步验证密钥，由 /account/2fa 页面写入
+	RecoveryCodes []string `yaml:"recovery_codes,omitempty"` // 恢复码哈希，每个只能使用一次
+}
+
+type usersFile struct {
+	Users []Account `yaml:"users"`
+}
+
+var (
+	accounts   []Account
+	accountsMu sync.RWMutex
+)
+
+// 用于账号不存在时的等时比较，避免通过响应时间枚举用户名
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("plist"), bcrypt.DefaultCost)
+
+// 加载用户文件，文件不存在时沿用单一访问密码
+func loadUsers() {
+	content, err := os.ReadFile(usersFileName)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("无法读取用户文件 %s: %v", usersFileName, err)
+	}
+	var file usersFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		log.Fatalf("无法解析用户文件 %s: %v", usersFileName, err)
+	}
+
+	changed := false
+	seen := make(map[string]bool)
+	for i := range file.Users {
+		account := &file.Users[i]
+		if account.Username == "" {
+			log.Fatalf("用户文件 %s 第%d项缺少 username", usersFileName, i+1)
+		}
+		if seen[account.Username] {
+			log.Fatalf("用户文件 %s 中用户名 %s 重复", usersFileName, account.Username)
+		}
+		seen[account.Username] = true
+
+		if account.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(account.Password), bcrypt.DefaultCost)
+			if err != nil {
+				log.Fatalf("无法为用户 %s 生成密码哈希: %v", account.Username, err)
+			}
+			account.PasswordHash = string(hash)
+			account.Password = ""
+			changed = true
+		}
+		switch account.Role {
+		case roleAdmin, roleViewer:
+		case "":
+			account.Role = roleViewer
+		default:
+			log.Fatalf("用户 %s 的角色 %s 无效，可选 admin 或 viewer", account.Username, account.Role)
+		}
+	}
+
+	accounts = file.Users
+	log.Printf("已加载 %d 个用户", len(accounts))
+	if changed {
+		saveUsers()
+		log.Println("已将明文密码转换为哈希并写回用户文件")
+	}
+}
+
+// 将账号写回用户文件，调用方需持有锁或处于初始化阶段
+func saveUsers() {
+	content, err := yaml.Marshal(usersFile{Users: accounts})
+	if err != nil {
+		log.Printf("无法序列化用户文件: %v", err)
+		return
+	}
+	if err := os.WriteFile(usersFileName, content, 0600); err != nil {
+		log.Printf("无法写入用户文件 %s: %v", usersFileName, err)
+	}
+}
+
+// 是否启用多用户登录
+func multiUser() bool {
+	accountsMu.RLock()
+	defer accountsMu.RUnlock()
+	return len(accounts) > 0
+}
+
+func findAccount(username string) (Account, bool) {
+	accountsMu.RLock()
+	defer accountsMu.RUnlock()
+	for _, account := range accounts {
+		if account.Username == username {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// 修改账号并写回用户文件
+func updateAccount(username string, update func(*Account)) {
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+	for i := range accounts {
+		if accounts[i].Username == username {
+			update(&accounts[i])
+			saveUsers()
+			return
+		}
+	}
+}
+
+// 校验用户名与密码
+func checkPassword(username, password string) (Account, bool) {
+	account, ok := findAccount(strings.TrimSpace(username))
+	if !ok || account.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return Account{}, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)) != nil {
+		return Account{}, false
+	}
+	return account, true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 加载用户文件时将明文密码转换为哈希并写回，未填写角色的用户为 viewer
+func TestLoadUsers(t *testing.T) {
+	t.Cleanup(func() {
+		accountsMu.Lock()
+		accounts = nil
+		accountsMu.Unlock()
+		os.Remove(usersFileName)
+	})
+	users := "users:\n  - username: alice\n    password: pw1\n    role: admin\n  - username: bob\n    password: pw2\n"
+	if err := os.WriteFile(usersFileName, []byte(users), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loadUsers()
+
+	content, err := os.ReadFile(usersFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "pw1") || !strings.Contains(string(content), "password_hash: $2") {
+		t.Errorf("用户文件中的明文密码未转换为哈希:\n%s", content)
+	}
+	for _, tt := range []struct {
+		username, password, role string
+		ok                       bool
+	}{
+		{"alice", "pw1", roleAdmin, true},
+		{" bob ", "pw2", roleViewer, true},
+		{"alice", "pw2", "", false},
+		{"carol", "pw1", "", false},
+	} {
+		account, ok := checkPassword(tt.username, tt.password)
+		if ok != tt.ok || account.Role != tt.role {
+			t.Errorf("checkPassword(%q, %q) = %v %q", tt.username, tt.password, ok, account.Role)
+		}
+	}
+}
+
+// 多用户模式下按用户名与密码登录
+func TestLoginMultiUser(t *testing.T) {
+	accountsMu.Lock()
+	accounts = []Account{{Username: "dave", PasswordHash: string(dummyPasswordHash), Role: roleViewer}}
+	accountsMu.Unlock()
+	t.Cleanup(func() {
+		accountsMu.Lock()
+		accounts = nil
+		accountsMu.Unlock()
+	})
+	login := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {"dave"}, "password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		loginHandler(rec, req)
+		return rec
+	}
+
+	if rec := login("wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("密码错误返回 %d，期望 401", rec.Code)
+	}
+	rec := login("plist")
+	if rec.Code != http.StatusFound {
+		t.Fatalf("登录返回 %d", rec.Code)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name != "auth" {
+			continue
+		}
+		if claims, ok := verifyCookie(cookie); !ok || claims.Subject != "user:dave" {
+			t.Errorf("认证cookie主体不正确: %+v", claims)
+		}
+		return
+	}
+	t.Error("未设置认证cookie")
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 合并短时间内的连续变更，避免批量复制图片时反复扫描
+const watchDebounce = 500 * time.Millisecond
+
+var (
+	// 分类缓存只整体替换不原地修改，读取方拿到的切片可以直接使用
+	categoryCache   []Category
+	categoryCacheMu sync.RWMutex
+)
+
+// 子分类的扫描结果，分类页每次打开都要列出子分类，逐个扫描需要遍历整个子目录
+type childScan struct {
+	category Category
+	ok       bool
+}
+
+var (
+	// 键为子分类路径，随所属顶层分类的刷新一同失效
+	childCategoryCache   = make(map[string]childScan)
+	childCategoryCacheMu sync.Mutex
+)
+
+// 扫描子分类，优先使用缓存
+func scanChildCategory(name string) (Category, bool) {
+	childCategoryCacheMu.Lock()
+	cached, found := childCategoryCache[name]
+	childCategoryCacheMu.Unlock()
+	if found {
+		return cached.category, cached.ok
+	}
+	category, ok := scanCategory(name)
+	childCategoryCacheMu.Lock()
+	childCategoryCache[name] = childScan{category, ok}
+	childCategoryCacheMu.Unlock()
+	return category, ok
+}
+
+// 移除顶层分类下缓存的子分类，top 为空时全部移除
+func invalidateChildCategories(top string) {
+	childCategoryCacheMu.Lock()
+	defer childCategoryCacheMu.Unlock()
+	if top == "" {
+		clear(childCategoryCache)
+		return
+	}
+	for name := range childCategoryCache {
+		if strings.HasPrefix(name, top+"/") {
+			delete(childCategoryCache, name)
+		}
+	}
+}
+
+func getCategories() []Category {
+	categoryCacheMu.RLock()
+	defer categoryCacheMu.RUnlock()
+	return categoryCache
+}
+
+func setCategories(categories []Category) {
+	categoryCacheMu.Lock()
+	categoryCache = categories
+	categoryCacheMu.Unlock()
+	refreshSearchIndex()
+}
+
+// 重新扫描单个分类并更新缓存与搜索索引，分类已删除、已隐藏或不再包含图片时从缓存移除。
+// 以点开头的目录与文件不是分类，与全量扫描一致直接忽略；根目录下既非目录也非归档的文件同样不是分类
+func refreshCategory(name string) {
+	if hiddenName(name) {
+		return
+	}
+	defer invalidateSearchIndex(name)
+	invalidateChildCategories(name)
+	var category Category
+	ok := false
+	if info, err := fs.Stat(imageStore, name); err == nil && (info.IsDir() || isArchiveName(name)) {
+		category, ok = scanCategory(name)
+		ok = ok && !category.Hidden
+	}
+
+	categoryCacheMu.Lock()
+	defer categoryCacheMu.Unlock()
+	index := slices.IndexFunc(categoryCache, func(c Category) bool {
+		return c.Path == name
+	})
+	found := index >= 0
+	switch {
+	case ok && found:
+		if sameCategory(categoryCache[index], category) {
+			return
+		}
+		categoryCache = slices.Clone(categoryCache)
+		categoryCache[index] = category
+	case ok:
+		categoryCache = append(slices.Clone(categoryCache), category)
+		log.Printf("新增分类：%s", name)
+	case found:
+		categoryCache = slices.Delete(slices.Clone(categoryCache), index, index+1)
+		log.Printf("移除分类：%s", name)
+		return
+	default:
+		return
+	}
+	sortCategories(categoryCache)
+}
+
+func sameCategory(a, b Category) bool {
+	return a.Path == b.Path && a.CoverImage == b.CoverImage && a.Title == b.Title &&
+		a.Description == b.Description && a.SortKey == b.SortKey && slices.Equal(a.Tags, b.Tags) &&
+		a.ImageCount == b.ImageCount && a.modTime.Equal(b.modTime)
+}
+
+// 重新扫描全部分类，目录暂时不可读时保留原有缓存
+func refreshAllCategories() {
+	categories, err := readCategories()
+	if err != nil {
+		log.Printf("无法读取图片存储: %v", err)
+		return
+	}
+	invalidateChildCategories("")
+	if !slices.EqualFunc(getCategories(), categories, sameCategory) {
+		setCategories(categories)
+		log.Printf("分类已更新，共 %d 个", len(categories))
+	}
+}
+
+// 轮询间隔，本地目录默认10秒；对象存储每次轮询都要列出全部目录，默认10分钟
+func watchInterval() time.Duration {
+	if config.WatchInterval > 0 {
+		return time.Duration(config.WatchInterval) * time.Second
+	}
+	if !localStorage() {
+		return 10 * time.Minute
+	}
+	return 10 * time.Second
+}
+
+// 监听图片目录变化，watch 为 off 时关闭，为 poll 时定时轮询，默认优先使用系统文件通知，不可用时改为轮询。
+// 对象存储没有变更通知，轮询按请求计费，只在 watch 为 poll 时开启
+func startCategoryWatcher() {
+	switch {
+	case config.Watch == "off":
+		return
+	case config.Watch == "poll":
+		go pollCategories()
+		return
+	case !localStorage():
+		log.Printf("对象存储不支持变更通知，未开启轮询，新增的图片需重启后显示；可将 watch 设为 poll 定时轮询")
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("无法启用文件监听，改为每 %s 轮询: %v", watchInterval(), err)
+		go pollCategories()
+		return
+	}
+	if err := watcher.Add(config.ImageDir); err != nil {
+		watcher.Close()
+		log.Printf("无法监听目录 %s，改为每 %s 轮询: %v", config.ImageDir, watchInterval(), err)
+		go pollCategories()
+		return
+	}
+	watchCategoryDirs(watcher)
+	go watchCategories(watcher)
+}
+
+// 监听图片目录下的所有子目录，已监听的目录重复添加不会产生影响
+func watchCategoryDirs(watcher *fsnotify.Watcher) {
+	watchTree(watcher, config.ImageDir)
+}
+
+// 递归监听目录及其子目录，子分类中的变化同样需要更新顶层分类的封面
+func watchTree(watcher *fsnotify.Watcher, root string) {
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if p == config.ImageDir {
+			return nil
+		}
+		if hiddenName(d.Name()) {
+			return filepath.SkipDir
+		}
+		watchDir(watcher, p)
+		return nil
+	})
+}
+
+func watchDir(watcher *fsnotify.Watcher, dir string) {
+	if err := watcher.Add(dir); err != nil {
+		log.Printf("无法监听目录 %s: %v", dir, err)
+	}
+}
+
+func pollCategories() {
+	ticker := time.NewTicker(watchInterval())
+	defer ticker.Stop()
+	for range ticker.C {
+		refreshAllCategories()
+	}
+}
+
+// 处理文件通知，按分类记录待刷新项，静默一段时间后统一扫描
+func watchCategories(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	pending := make(map[string]bool)
+	fullRescan := false
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			rel, err := filepath.Rel(config.ImageDir, event.Name)
+			if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			name, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+			if event.Has(fsnotify.Create) {
+				// 新建的目录需要单独监听才能收到其中图片的变化
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watchTree(watcher, event.Name)
+				}
+			}
+			pending[name] = true
+			timer.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// 通知队列溢出，部分变更已丢失，需要全量扫描
+				fullRescan = true
+				timer.Reset(watchDebounce)
+				continue
+			}
+			log.Printf("文件监听出错: %v", err)
+		case <-timer.C:
+			if fullRescan {
+				watchCategoryDirs(watcher)
+				refreshAllCategories()
+			} else {
+				for name := range pending {
+					refreshCategory(name)
+				}
+			}
+			clear(pending)
+			fullRescan = false
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRefreshCategorySkipsHiddenNames(t *testing.T) {
+	saved := getCategories()
+	t.Cleanup(func() {
+		setCategories(saved)
+		os.RemoveAll(filepath.Join(config.ImageDir, ".trash"))
+		os.RemoveAll(filepath.Join(config.ImageDir, "Trips"))
+	})
+	writeImageFile(t, ".trash/a.jpg", []byte("jpeg"))
+	writeImageFile(t, "Trips/b.jpg", []byte("jpeg"))
+
+	refreshCategory(".trash")
+	refreshCategory("Trips")
+	paths := []string{}
+	for _, category := range getCategories() {
+		paths = append(paths, category.Path)
+	}
+	if slices.Contains(paths, ".trash") {
+		t.Errorf("隐藏目录不应成为分类: %v", paths)
+	}
+	if !slices.Contains(paths, "Trips") {
+		t.Errorf("新增分类未加入缓存: %v", paths)
+	}
+}
+
+// 根目录下的普通文件不是分类，刷新时直接忽略，不应尝试按目录读取
+func TestRefreshCategorySkipsPlainFiles(t *testing.T) {
+	saved := getCategories()
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		setCategories(saved)
+		os.Remove(filepath.Join(config.ImageDir, "stray.jpg"))
+	})
+	writeImageFile(t, "stray.jpg", []byte("jpeg"))
+
+	refreshCategory("stray.jpg")
+	if logs.Len() > 0 {
+		t.Errorf("刷新普通文件时输出日志: %s", logs.String())
+	}
+	if slices.ContainsFunc(getCategories(), func(c Category) bool { return c.Path == "stray.jpg" }) {
+		t.Error("普通文件不应成为分类")
+	}
+}
+
+// 子分类的扫描结果被缓存，所属顶层分类刷新后重新扫描
+func TestChildCategoryCache(t *testing.T) {
+	t.Cleanup(func() {
+		invalidateChildCategories("")
+		os.RemoveAll(filepath.Join(config.ImageDir, "ChildCache"))
+	})
+	writeImageFile(t, "ChildCache/a.jpg", []byte("jpeg"))
+	writeImageFile(t, "ChildCache/Sub/b.jpg", []byte("jpeg"))
+	childCover := func() string {
+		_, children, err := readCategoryDir("ChildCache")
+		if err != nil || len(children) != 1 {
+			t.Fatalf("readCategoryDir: %v %v", children, err)
+		}
+		return children[0].CoverImage
+	}
+	if cover := childCover(); cover != "b.jpg" {
+		t.Fatalf("子分类封面为 %s，期望 b.jpg", cover)
+	}
+
+	writeImageFile(t, "ChildCache/Sub/a.jpg", []byte("jpeg"))
+	if cover := childCover(); cover != "b.jpg" {
+		t.Errorf("未刷新时应使用缓存，封面为 %s", cover)
+	}
+	refreshCategory("ChildCache")
+	if cover := childCover(); cover != "a.jpg" {
+		t.Errorf("刷新后封面为 %s，期望 a.jpg", cover)
+	}
+}